@@ -0,0 +1,125 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// KeyStat describes how often and how recently a key has been read.
+type KeyStat struct {
+	Key        string
+	Hits       uint64
+	LastAccess time.Time
+}
+
+// TopKeys returns up to n live keys ordered by descending hit count.
+// Hits count successful Gets since the key was last Set. It returns nil unless
+// the storage was created with WithAccessTracking.
+func (s *Storage) TopKeys(n int) []KeyStat {
+	if !s.trackAccess || n <= 0 {
+		return nil
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	stats := make([]KeyStat, 0, len(s.data))
+	for key, item := range s.data {
+		if item.isExpiredAt(now) {
+			continue
+		}
+		stats = append(stats, item.keyStat(key))
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Hits != stats[j].Hits {
+			return stats[i].Hits > stats[j].Hits
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// CreatedAt returns when the value stored under key was written. Every write of a new value,
+// including Set overwriting the key, CompareAndSwap and Increment, resets it, while Touch,
+// MoveTo and Restore keep it.
+func (s *Storage) CreatedAt(key string) (time.Time, error) {
+	if err := s.checkOpen(); err != nil {
+		return time.Time{}, err
+	}
+	if err := s.rlock(); err != nil {
+		return time.Time{}, err
+	}
+	item, exists := s.data[key]
+	s.mu.RUnlock()
+
+	if !exists {
+		return time.Time{}, keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		return time.Time{}, keyExpired(key)
+	}
+	return item.createdAt, nil
+}
+
+// AgeRange returns the ages of the oldest and newest live entries, measured from when their
+// values were written as reported by CreatedAt, for a quick sense of how long entries stay.
+// ok is false if storage holds no live entries. The range is computed on demand with a scan
+// of every entry under the read lock rather than tracked on writes, so it is always exact
+// but costs time proportional to the number of entries.
+func (s *Storage) AgeRange() (oldest, newest time.Duration, ok bool) {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var first, last time.Time
+	for _, item := range s.data {
+		if item.isExpiredAt(now) {
+			continue
+		}
+		if !ok || item.createdAt.Before(first) {
+			first = item.createdAt
+		}
+		if !ok || item.createdAt.After(last) {
+			last = item.createdAt
+		}
+		ok = true
+	}
+	if !ok {
+		return 0, 0, false
+	}
+	return now.Sub(first), now.Sub(last), true
+}
+
+// recordAccess counts a hit on the item at the given time.
+func (i *item) recordAccess(now time.Time) {
+	atomic.AddUint64(&i.hits, 1)
+	atomic.StoreInt64(&i.lastAccess, now.UnixNano())
+}
+
+// keyStat returns the access statistics of the item stored under key.
+func (i *item) keyStat(key string) KeyStat {
+	stat := KeyStat{Key: key, Hits: atomic.LoadUint64(&i.hits)}
+	if lastAccess := atomic.LoadInt64(&i.lastAccess); lastAccess != 0 {
+		stat.LastAccess = time.Unix(0, lastAccess)
+	}
+	return stat
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStorage_TopKeys(t *testing.T) {
+	store := New(WithAccessTracking())
+
+	// Read keys with a skewed distribution: key0 is hottest, key4 is never read.
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		store.Set(key, i, 0)
+		for j := 0; j < (4-i)*10; j++ {
+			store.Get(key)
+		}
+	}
+	store.Set("expiredKey", "value", 50*time.Millisecond)
+	for j := 0; j < 100; j++ {
+		store.Get("expiredKey")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	top := store.TopKeys(3)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 keys, but got %d", len(top))
+	}
+	for i, stat := range top {
+		expectedKey := fmt.Sprintf("key%d", i)
+		expectedHits := uint64((4 - i) * 10)
+		if stat.Key != expectedKey || stat.Hits != expectedHits {
+			t.Errorf("Expected %s with %d hits at %d, but got %s with %d hits", expectedKey, expectedHits, i, stat.Key, stat.Hits)
+		}
+		if stat.LastAccess.IsZero() {
+			t.Errorf("Expected a last access time for %s", stat.Key)
+		}
+	}
+
+	// Test all live keys being returned when n exceeds the store size.
+	all := store.TopKeys(10)
+	if len(all) != 5 {
+		t.Errorf("Expected 5 live keys, but got %d", len(all))
+	}
+	if !all[4].LastAccess.IsZero() {
+		t.Errorf("Expected no last access time for an unread key")
+	}
+}
+
+func TestStorage_TopKeysDisabled(t *testing.T) {
+	store := New()
+	store.Set("key", "value", 0)
+	store.Get("key")
+
+	if top := store.TopKeys(1); top != nil {
+		t.Errorf("Expected nil without access tracking, but got %v", top)
+	}
+}
+
+// Test that AgeRange reports the ages of the oldest and newest live entries.
+func TestStorage_AgeRange(t *testing.T) {
+	store := New()
+	start := time.Now()
+	now := start
+	store.now = func() time.Time { return now }
+
+	if _, _, ok := store.AgeRange(); ok {
+		t.Errorf("Expected no age range for empty storage")
+	}
+
+	store.Set("oldest", "value", 0)
+	now = start.Add(time.Minute)
+	store.Set("expiring", "value", time.Second)
+	now = start.Add(2 * time.Minute)
+	store.Set("middle", "value", 0)
+	now = start.Add(3 * time.Minute)
+	store.Set("newest", "value", 0)
+	now = start.Add(5 * time.Minute)
+
+	oldest, newest, ok := store.AgeRange()
+	if !ok || oldest != 5*time.Minute || newest != 2*time.Minute {
+		t.Errorf("Expected ages 5m0s and 2m0s, but got %v, %v, %v", oldest, newest, ok)
+	}
+
+	// Test deletions narrowing the range.
+	store.Delete("oldest")
+	if oldest, newest, ok = store.AgeRange(); !ok || oldest != 3*time.Minute || newest != 2*time.Minute {
+		t.Errorf("Expected ages 3m0s and 2m0s, but got %v, %v, %v", oldest, newest, ok)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "time"
+
+// queuedDelete is a deletion queued by Delete with WithAsyncDelete, along with the item
+// stored under the key when it was queued.
+type queuedDelete struct {
+	key  string
+	item *item
+}
+
+// enqueueDelete queues the deletion of key for the async delete goroutine. It reports
+// false if deletions are not asynchronous, the queue is full or storage was closed, in
+// which case the caller must delete the key itself.
+func (s *Storage) enqueueDelete(key string) bool {
+	if s.deleteQueue == nil {
+		return false
+	}
+
+	s.deleteMu.RLock()
+	defer s.deleteMu.RUnlock()
+	if s.deleteClosed {
+		return false
+	}
+	s.mu.RLock()
+	item := s.data[key]
+	s.dropPendingWrite(key)
+	s.mu.RUnlock()
+	select {
+	case s.deleteQueue <- queuedDelete{key: key, item: item}:
+		return true
+	default:
+		return false
+	}
+}
+
+// startAsyncDelete starts the goroutine applying queued deletions every interval.
+func (s *Storage) startAsyncDelete(interval time.Duration) {
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.deleteStop, s.deleteDone = stop, done
+	s.safeGo(func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.flushDeletes()
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// stopAsyncDelete stops the async delete goroutine and applies the deletions still queued.
+// Later calls to Delete delete synchronously.
+func (s *Storage) stopAsyncDelete() {
+	if s.deleteQueue == nil {
+		return
+	}
+
+	s.deleteMu.Lock()
+	if s.deleteClosed {
+		s.deleteMu.Unlock()
+		return
+	}
+	s.deleteClosed = true
+	s.deleteMu.Unlock()
+
+	close(s.deleteStop)
+	<-s.deleteDone
+	s.flushDeletes()
+}
+
+// flushDeletes applies the queued deletions under a single write lock. A key set again
+// since its deletion was queued keeps its new value.
+func (s *Storage) flushDeletes() {
+	var deletes []queuedDelete
+drain:
+	for len(deletes) < cap(s.deleteQueue) {
+		select {
+		case queued := <-s.deleteQueue:
+			deletes = append(deletes, queued)
+		default:
+			break drain
+		}
+	}
+	if len(deletes) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, queued := range deletes {
+		if queued.item != nil && s.data[queued.key] == queued.item {
+			s.removeItem(queued.key)
+		}
+		delete(s.markers, queued.key)
+	}
+}
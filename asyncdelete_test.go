@@ -0,0 +1,76 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStorage_AsyncDelete(t *testing.T) {
+	store := New(WithAsyncDelete(100, 10*time.Millisecond))
+	defer store.Close()
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+
+	// Test queued deletions being applied eventually.
+	for i := 0; i < 5; i++ {
+		store.Delete(fmt.Sprintf("key%d", i))
+	}
+	waitFor(t, func() bool {
+		for i := 0; i < 5; i++ {
+			if _, err := store.Get(fmt.Sprintf("key%d", i)); err == nil {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Test a key set again after its deletion was queued keeping its new value.
+	store.Delete("key5")
+	store.Set("key5", "new", 0)
+	time.Sleep(30 * time.Millisecond)
+	if value, err := store.Get("key5"); err != nil || value != "new" {
+		t.Errorf("Expected new, got %v, %v", value, err)
+	}
+}
+
+func TestStorage_AsyncDeleteClose(t *testing.T) {
+	store := New(WithAsyncDelete(100, time.Hour))
+	store.Set("key", "value", 0)
+	store.Set("otherKey", "value", 0)
+
+	// Test the deletion staying queued until Close flushes it.
+	store.Delete("key")
+	if _, err := store.Get("key"); err != nil {
+		t.Errorf("Expected the key to be readable before the flush, but got %v", err)
+	}
+	store.Close()
+	if _, exists := store.data["key"]; exists {
+		t.Errorf("Expected the key to be deleted by Close")
+	}
+	if len(store.deleteQueue) != 0 {
+		t.Errorf("Expected an empty queue, but got %d deletions", len(store.deleteQueue))
+	}
+
+	// Test deletions after Close being ignored.
+	store.Delete("otherKey")
+	if _, exists := store.data["otherKey"]; !exists {
+		t.Errorf("Expected a deletion after Close to be ignored")
+	}
+	store.Close()
+}
@@ -0,0 +1,375 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// GetSlice retrieves the values of keys under a single read lock. The returned slices are
+// aligned with keys: for a missing or expired key the value is nil and the error is set.
+func (s *Storage) GetSlice(keys []string) ([]interface{}, []error) {
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	s.markActive()
+	if s.isClosed() {
+		for i := range errs {
+			errs[i] = ErrClosed
+		}
+		return values, errs
+	}
+
+	now := s.now()
+	if err := s.rlock(); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return values, errs
+	}
+	for i, key := range keys {
+		item, exists := s.data[key]
+		switch {
+		case !exists:
+			atomic.AddUint64(&s.misses, 1)
+			errs[i] = keyNotFound(key)
+		case item.isExpiredAt(now):
+			atomic.AddUint64(&s.misses, 1)
+			errs[i] = keyExpired(key)
+		default:
+			atomic.AddUint64(&s.hits, 1)
+			if s.trackAccess {
+				item.recordAccess(now)
+			}
+			s.markUsed(item, now)
+			values[i], errs[i] = s.readValue(item)
+		}
+	}
+	s.mu.RUnlock()
+	return values, errs
+}
+
+// GetConsistent retrieves the values of keys as a map from key to value, reading them all
+// within a single hold of the read lock, so no write can land between two of the reads:
+// the values form a consistent snapshot, as of one point in time, of keys that are updated
+// together, such as with MergeMap. It fails with the error of the first missing or expired
+// key, in which case no values are returned. Unlike Get, it does not consult the loader.
+func (s *Storage) GetConsistent(keys ...string) (values map[string]interface{}, err error) {
+	// failed is the key the error is about, if any.
+	var failed string
+	defer func() { wrapOpError("GetConsistent", failed, &err) }()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	s.markActive()
+	if err := s.rlock(); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	values = make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		item, exists := s.lookup(key)
+		switch {
+		case !exists:
+			atomic.AddUint64(&s.misses, 1)
+			err = keyNotFound(key)
+		case item.isExpiredAt(now):
+			atomic.AddUint64(&s.misses, 1)
+			err = keyExpired(key)
+		default:
+			values[key], err = s.hit(item, now)
+		}
+		if err != nil {
+			failed = key
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// HasMany reports for each of keys whether a live value is stored under it, all under a
+// single read lock, so the answers are consistent with each other. Expired keys report
+// false. Unlike Has, it does not consult the exists loader on a miss.
+func (s *Storage) HasMany(keys []string) map[string]bool {
+	s.markActive()
+	if s.isClosed() {
+		return map[string]bool{}
+	}
+	found := make(map[string]bool, len(keys))
+
+	now := s.now()
+	s.mu.RLock()
+	for _, key := range keys {
+		item, exists := s.lookup(key)
+		found[key] = exists && !item.isExpiredAt(now)
+	}
+	s.mu.RUnlock()
+	return found
+}
+
+// DeleteMany removes keys under a single write lock, or one per chunk with
+// WithBatchChunkSize, and returns the subset that existed, in the order they were given.
+// Expired keys that had not been cleaned up yet still count as removed.
+func (s *Storage) DeleteMany(keys []string) []string {
+	s.markActive()
+	if s.isClosed() {
+		return nil
+	}
+	var removed []string
+
+	s.mu.Lock()
+	for i, key := range keys {
+		s.yieldBetweenChunks(i)
+		s.dropPendingWrite(key)
+		if _, exists := s.data[key]; exists {
+			s.removeItem(key)
+			removed = append(removed, key)
+		}
+		delete(s.markers, key)
+	}
+	s.mu.Unlock()
+	return removed
+}
+
+// SetKeys sets every key in keys to value with the given TTL under a single write lock.
+// The keys are all validated first, and if any of them is invalid or immutable, nothing is
+// stored. With WithBatchChunkSize, the lock is taken once per chunk instead, and an
+// immutable key only stops the keys from it onwards from being stored.
+//
+// The keys share value itself rather than copies of it, so mutating a value held by
+// reference, such as a pointer, slice or map, is seen through all of them; store
+// immutable values or use WithSerializedValues to avoid this.
+func (s *Storage) SetKeys(keys []string, value interface{}, ttl time.Duration) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	items := make([]*item, len(keys))
+	for i, key := range keys {
+		item, err := s.prepareItem(key, value, ttl)
+		if err != nil {
+			return err
+		}
+		items[i] = item
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if err := s.makeRoom(context.Background(), keys...); err != nil {
+		return err
+	}
+	if s.batchChunk <= 0 {
+		for _, key := range keys {
+			if err := s.checkWritable(key); err != nil {
+				return err
+			}
+		}
+	}
+	for i, key := range keys {
+		s.yieldBetweenChunks(i)
+		if err := s.checkWritable(key); err != nil {
+			return err
+		}
+		s.storeItem(key, items[i])
+	}
+	return nil
+}
+
+// yieldBetweenChunks briefly releases the write lock before the i-th item of a batch if it
+// starts a new chunk, letting waiting readers and writers in. The caller must hold the write lock.
+func (s *Storage) yieldBetweenChunks(i int) {
+	if s.batchChunk > 0 && i > 0 && i%s.batchChunk == 0 {
+		s.mu.Unlock()
+		s.mu.Lock()
+	}
+}
+
+// MergeMap stores items with the given TTL under a single write lock, letting onConflict
+// decide the value of keys that already hold a live value: it is called with the stored
+// and the incoming value, and what it returns is stored. Keys without a live value are
+// stored directly. The merge is all-or-nothing: if any key or resolved value is invalid,
+// or any key is immutable, the error is returned and nothing is stored or evicted.
+//
+// Values are resolved and prepared, running onConflict and the functions set with
+// WithSetInterceptor and WithTTLFunc, before the write lock is taken. If a conflicting key
+// changes in the meantime, the merge starts over, so onConflict may be called more than
+// once for a key and must not have side effects. It must not call back into the storage.
+func (s *Storage) MergeMap(items map[string]interface{}, ttl time.Duration, onConflict func(key string, oldVal, newVal interface{}) interface{}) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	for key := range items {
+		if err := s.validateKeyAndTTL(key, ttl); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	for {
+		current, err := s.liveItems(keys)
+		if err != nil {
+			return err
+		}
+		merged, err := s.prepareMerge(items, current, ttl, onConflict)
+		if err != nil {
+			return err
+		}
+		stored, err := s.storeMerge(keys, current, merged)
+		if err != nil || stored {
+			return err
+		}
+	}
+}
+
+// mergeBase is a live item read by MergeMap and its version, to tell whether it has
+// changed since it was read.
+type mergeBase struct {
+	item    *item
+	version uint64
+}
+
+// liveItems returns the live items stored under keys, read under one hold of the read lock.
+func (s *Storage) liveItems(keys []string) (map[string]mergeBase, error) {
+	if err := s.rlock(); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
+	now := s.now()
+	current := make(map[string]mergeBase)
+	for _, key := range keys {
+		if item, exists := s.data[key]; exists && !item.isExpiredAt(now) {
+			current[key] = mergeBase{item: item, version: item.version}
+		}
+	}
+	return current, nil
+}
+
+// prepareMerge resolves the value of every key of items against its live item in current
+// and prepares it for storing, without holding the lock.
+func (s *Storage) prepareMerge(items map[string]interface{}, current map[string]mergeBase, ttl time.Duration, onConflict func(key string, oldVal, newVal interface{}) interface{}) (map[string]*item, error) {
+	merged := make(map[string]*item, len(items))
+	for key, value := range items {
+		if base, exists := current[key]; exists {
+			old, err := s.decode(base.item.value)
+			if err != nil {
+				s.recycleItems(merged)
+				return nil, err
+			}
+			value = onConflict(key, old, value)
+		}
+		item, err := s.prepareItem(key, value, ttl)
+		if err != nil {
+			s.recycleItems(merged)
+			return nil, err
+		}
+		merged[key] = item
+	}
+	return merged, nil
+}
+
+// storeMerge stores the items of a merge prepared against current under the write lock. It
+// reports false, storing nothing, if a key no longer holds the live item it was resolved
+// against, in which case the merge must be prepared again.
+func (s *Storage) storeMerge(keys []string, current map[string]mergeBase, merged map[string]*item) (bool, error) {
+	if err := s.lock(); err != nil {
+		s.recycleItems(merged)
+		return false, err
+	}
+	defer s.mu.Unlock()
+
+	if s.mergeBaseChanged(keys, current) {
+		s.recycleItems(merged)
+		return false, nil
+	}
+	for _, key := range keys {
+		if err := s.checkWritable(key); err != nil {
+			s.recycleItems(merged)
+			return false, err
+		}
+	}
+	if err := s.makeRoom(context.Background(), keys...); err != nil {
+		s.recycleItems(merged)
+		return false, err
+	}
+	// Making room may have waited with PolicyBlock, or evicted one of the keys.
+	if s.mergeBaseChanged(keys, current) {
+		s.recycleItems(merged)
+		return false, nil
+	}
+	for key, item := range merged {
+		s.storeItem(key, item)
+	}
+	return true, nil
+}
+
+// mergeBaseChanged reports whether any of keys no longer holds the live item it held in
+// current. The caller must hold the write lock.
+func (s *Storage) mergeBaseChanged(keys []string, current map[string]mergeBase) bool {
+	now := s.now()
+	for _, key := range keys {
+		item, exists := s.data[key]
+		live := exists && !item.isExpiredAt(now)
+		base, wasLive := current[key]
+		if live != wasLive || live && (item != base.item || item.version != base.version) {
+			return true
+		}
+	}
+	return false
+}
+
+// recycleItems recycles the prepared items of a merge that was not stored.
+func (s *Storage) recycleItems(items map[string]*item) {
+	for _, item := range items {
+		s.recycleItem(item)
+	}
+}
+
+// Warm populates storage from source and blocks until it is done, so a service can be
+// ready before it serves traffic. Each entry passed to yield is validated and stored as
+// with Set. Warming is best-effort: it aborts on the first invalid entry, on an error from
+// source, or when ctx is done, and returns that error while keeping the entries stored so far.
+// Once aborted, every further call to yield returns the same error.
+func (s *Storage) Warm(ctx context.Context, source func(yield func(key string, value interface{}, ttl time.Duration) error) error) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	var failed error
+	yield := func(key string, value interface{}, ttl time.Duration) error {
+		if failed != nil {
+			return failed
+		}
+		if failed = ctx.Err(); failed != nil {
+			return failed
+		}
+		failed = s.Set(key, value, ttl)
+		return failed
+	}
+
+	if err := source(yield); err != nil {
+		return err
+	}
+	if failed != nil {
+		return failed
+	}
+	return ctx.Err()
+}
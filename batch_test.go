@@ -0,0 +1,387 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test that GetConsistent never observes a pair of keys half-updated by a concurrent writer.
+func TestStorage_GetConsistent(t *testing.T) {
+	store := New()
+	store.Set("balance", 0, 0)
+	store.Set("lastTx", 0, 0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 1000; i++ {
+			store.MergeMap(map[string]interface{}{"balance": i, "lastTx": i}, 0, func(key string, oldVal, newVal interface{}) interface{} {
+				return newVal
+			})
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		values, err := store.GetConsistent("balance", "lastTx")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if values["balance"] != values["lastTx"] {
+			t.Fatalf("Expected a consistent pair, but got balance %v and lastTx %v", values["balance"], values["lastTx"])
+		}
+	}
+
+	_, err := store.GetConsistent("balance", "missingKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected %v, but got %v", ErrKeyNotFound, err)
+	}
+	var storeErr *StoreError
+	if !errors.As(err, &storeErr) || storeErr.Op != "GetConsistent" || storeErr.Key != "missingKey" {
+		t.Errorf("Expected a StoreError for GetConsistent on missingKey, but got %#v", err)
+	}
+
+	// Test closed storage being reported through a StoreError too.
+	store.Close()
+	_, err = store.GetConsistent("balance")
+	if !errors.Is(err, ErrClosed) || !errors.As(err, &storeErr) || storeErr.Op != "GetConsistent" {
+		t.Errorf("Expected a StoreError wrapping %v, but got %#v", ErrClosed, err)
+	}
+}
+
+func TestStorage_GetSlice(t *testing.T) {
+	store := New()
+	store.Set("key1", "value1", 0)
+	store.Set("key3", "value3", 0)
+	store.Set("expiredKey", "expiredValue", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	keys := []string{"key3", "missingKey", "key1", "expiredKey", "key3"}
+	values, errs := store.GetSlice(keys)
+	if len(values) != len(keys) || len(errs) != len(keys) {
+		t.Fatalf("Expected %d results, but got %d values and %d errors", len(keys), len(values), len(errs))
+	}
+
+	expectedValues := []interface{}{"value3", nil, "value1", nil, "value3"}
+	expectedErrs := []error{nil, ErrKeyNotFound, nil, ErrKeyExpired, nil}
+	for i := range keys {
+		if values[i] != expectedValues[i] {
+			t.Errorf("Expected %v at %d, but got %v", expectedValues[i], i, values[i])
+		}
+		if !errors.Is(errs[i], expectedErrs[i]) {
+			t.Errorf("Expected error %v at %d, but got %v", expectedErrs[i], i, errs[i])
+		}
+	}
+}
+
+func TestStorage_HasMany(t *testing.T) {
+	store := New()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Set("presentKey", "value", 0)
+	store.Set("expiredKey", "value", time.Second)
+	now = now.Add(2 * time.Second)
+
+	found := store.HasMany([]string{"presentKey", "absentKey", "expiredKey"})
+	expected := map[string]bool{"presentKey": true, "absentKey": false, "expiredKey": false}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected %v, but got %v", expected, found)
+	}
+}
+
+func TestStorage_DeleteMany(t *testing.T) {
+	store := New()
+	store.Set("key1", "value1", 0)
+	store.Set("key2", "value2", 0)
+	store.Set("expiredKey", "expiredValue", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	removed := store.DeleteMany([]string{"key2", "missingKey", "expiredKey", "key2"})
+	expected := []string{"key2", "expiredKey"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Expected %v, but got %v", expected, removed)
+	}
+
+	if _, err := store.Get("key2"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for key2, but got %v", err)
+	}
+	if value, err := store.Get("key1"); err != nil || value != "value1" {
+		t.Errorf("Expected key1 to be kept, got %v, %v", value, err)
+	}
+}
+
+func TestStorage_BatchChunkSize(t *testing.T) {
+	const numKeys = 200000
+	store := New(WithBatchChunkSize(100))
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	if err := store.SetKeys(keys, "value", 0); err != nil {
+		t.Fatalf("SetKeys() failed: %v", err)
+	}
+	if len(store.data) != numKeys {
+		t.Fatalf("Expected %d entries, but got %d", numKeys, len(store.data))
+	}
+
+	// Test a reader observing the batch halfway, with the first keys deleted and the last not yet.
+	var interleaved int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for atomic.LoadInt32(&interleaved) == 0 {
+			first, _ := store.Has(keys[0])
+			last, _ := store.Has(keys[numKeys-1])
+			if !first && last {
+				atomic.StoreInt32(&interleaved, 1)
+			}
+			if !first && !last {
+				return
+			}
+		}
+	}()
+	removed := store.DeleteMany(keys)
+	<-done
+
+	if len(removed) != numKeys || len(store.data) != 0 {
+		t.Errorf("Expected all %d keys to be removed, but got %d with %d left", numKeys, len(removed), len(store.data))
+	}
+	if atomic.LoadInt32(&interleaved) == 0 {
+		t.Errorf("Expected a reader to interleave with the chunked batch")
+	}
+}
+
+func TestStorage_SetKeys(t *testing.T) {
+	store := New()
+	keys := []string{"key1", "key2", "key3"}
+
+	if err := store.SetKeys(keys, "dirty", time.Minute); err != nil {
+		t.Fatalf("SetKeys() failed: %v", err)
+	}
+	for _, key := range keys {
+		if value, err := store.Get(key); err != nil || value != "dirty" {
+			t.Errorf("Expected dirty for %s, got %v, %v", key, value, err)
+		}
+	}
+
+	// Test an invalid key aborting the whole batch.
+	if err := store.SetKeys([]string{"key1", "", "key4"}, "clean", 0); err != ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, but got %v", err)
+	}
+	if value, _ := store.Get("key1"); value != "dirty" {
+		t.Errorf("Expected key1 to be untouched, but got %v", value)
+	}
+	if _, err := store.Get("key4"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected key4 not to be stored, but got %v", err)
+	}
+
+	// Test an immutable key aborting the whole batch.
+	store.SetOnce("immutableKey", "value", 0)
+	if err := store.SetKeys([]string{"key4", "immutableKey"}, "clean", 0); err != ErrImmutable {
+		t.Errorf("Expected ErrImmutable, but got %v", err)
+	}
+	if _, err := store.Get("key4"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected key4 not to be stored, but got %v", err)
+	}
+}
+
+func TestStorage_MergeMap(t *testing.T) {
+	store := New()
+	store.Set("keepOld", "old", 0)
+	store.Set("takeNew", "old", 0)
+	store.Set("computed", 1, 0)
+	store.Set("expiredKey", "old", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	var conflicts []string
+	err := store.MergeMap(map[string]interface{}{
+		"keepOld":    "new",
+		"takeNew":    "new",
+		"computed":   2,
+		"expiredKey": "new",
+		"newKey":     "new",
+	}, 0, func(key string, oldVal, newVal interface{}) interface{} {
+		conflicts = append(conflicts, key)
+		switch key {
+		case "keepOld":
+			return oldVal
+		case "computed":
+			return oldVal.(int) + newVal.(int)
+		default:
+			return newVal
+		}
+	})
+	if err != nil {
+		t.Fatalf("MergeMap() failed: %v", err)
+	}
+
+	sort.Strings(conflicts)
+	if expected := []string{"computed", "keepOld", "takeNew"}; !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected conflicts on %v, but got %v", expected, conflicts)
+	}
+	for key, expected := range map[string]interface{}{
+		"keepOld":    "old",
+		"takeNew":    "new",
+		"computed":   3,
+		"expiredKey": "new",
+		"newKey":     "new",
+	} {
+		if value, err := store.Get(key); err != nil || value != expected {
+			t.Errorf("Expected %v for %s, got %v, %v", expected, key, value, err)
+		}
+	}
+
+	// Test an invalid entry leaving storage untouched.
+	err = store.MergeMap(map[string]interface{}{"newKey": "newer", "": "value"}, 0, nil)
+	if err != ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, but got %v", err)
+	}
+	if value, _ := store.Get("newKey"); value != "new" {
+		t.Errorf("Expected newKey to be untouched, but got %v", value)
+	}
+}
+
+// Test that MergeMap prepares values without the lock and evicts nothing when it fails.
+func TestStorage_MergeMapPrepare(t *testing.T) {
+	var store *Storage
+	store = New(WithMaxEntries(2), WithSetInterceptor(func(key string, value interface{}) (interface{}, error) {
+		if !store.mu.TryLock() {
+			t.Errorf("Expected the interceptor to run without the lock for %s", key)
+		} else {
+			store.mu.Unlock()
+		}
+		if value == "invalid" {
+			return nil, ErrValueTooLarge
+		}
+		return value, nil
+	}))
+	store.Set("first", "value", 0)
+	store.Set("second", "value", 0)
+
+	// Test a failing item keeping the merge from evicting anything.
+	err := store.MergeMap(map[string]interface{}{"third": "value", "fourth": "invalid"}, 0, nil)
+	if !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge, but got %v", err)
+	}
+	for _, key := range []string{"first", "second"} {
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("Expected %s to survive the failed merge, but got %v", key, err)
+		}
+	}
+
+	// Test a key changing while its value is resolved making the merge start over.
+	calls := 0
+	err = store.MergeMap(map[string]interface{}{"first": "merged"}, 0, func(key string, oldVal, newVal interface{}) interface{} {
+		calls++
+		if calls == 1 {
+			store.Set("first", "changed", 0)
+		}
+		return oldVal.(string) + "+" + newVal.(string)
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Expected 2 conflict calls, got %d, %v", calls, err)
+	}
+	if value, _ := store.Get("first"); value != "changed+merged" {
+		t.Errorf("Expected changed+merged, but got %v", value)
+	}
+}
+
+func TestStorage_Warm(t *testing.T) {
+	store := New()
+
+	const numEntries = 100
+	source := func(yield func(key string, value interface{}, ttl time.Duration) error) error {
+		for i := 0; i < numEntries; i++ {
+			if err := yield(fmt.Sprintf("key%d", i), i, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := store.Warm(context.Background(), source)
+	if err != nil {
+		t.Fatalf("Warm() failed: %v", err)
+	}
+	for i := 0; i < numEntries; i++ {
+		value, err := store.Get(fmt.Sprintf("key%d", i))
+		if err != nil || value != i {
+			t.Errorf("Expected %d for key%d, got %v, %v", i, i, value, err)
+		}
+	}
+}
+
+func TestStorage_WarmAborts(t *testing.T) {
+	store := New()
+
+	// Test an invalid entry aborting the warm-up while keeping earlier entries.
+	err := store.Warm(context.Background(), func(yield func(key string, value interface{}, ttl time.Duration) error) error {
+		yield("key1", "value1", 0)
+		yield("", "value", 0)
+		yield("key2", "value2", 0)
+		return nil
+	})
+	if !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, but got %v", err)
+	}
+	if _, err := store.Get("key1"); err != nil {
+		t.Errorf("Expected key1 to be kept, but got %v", err)
+	}
+	if _, err := store.Get("key2"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected key2 to be skipped, but got %v", err)
+	}
+
+	// Test a source error being returned.
+	errSource := errors.New("source failed")
+	err = store.Warm(context.Background(), func(yield func(key string, value interface{}, ttl time.Duration) error) error {
+		return errSource
+	})
+	if err != errSource {
+		t.Errorf("Expected source error, but got %v", err)
+	}
+
+	// Test cancellation aborting the warm-up.
+	ctx, cancel := context.WithCancel(context.Background())
+	loaded := 0
+	err = store.Warm(ctx, func(yield func(key string, value interface{}, ttl time.Duration) error) error {
+		for i := 0; ; i++ {
+			if i == 10 {
+				cancel()
+			}
+			if err := yield(fmt.Sprintf("cancelKey%d", i), i, 0); err != nil {
+				return err
+			}
+			loaded++
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, but got %v", err)
+	}
+	if loaded != 10 {
+		t.Errorf("Expected 10 entries before cancellation, but got %d", loaded)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a Bloom filter over the keys ever stored. It only guards absence: a key
+// it does not contain was never stored, while a key it contains may or may not be stored.
+// It is not safe for concurrent use; storage guards it with its lock.
+type bloomFilter struct {
+	bits   []uint64
+	hashes uint64
+}
+
+// newBloomFilter returns a filter sized for n keys at the given false positive rate.
+func newBloomFilter(n int, falsePositiveRate float64) *bloomFilter {
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	return &bloomFilter{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		hashes: uint64(k),
+	}
+}
+
+// add adds key to the filter.
+func (f *bloomFilter) add(key string) {
+	h1, h2 := bloomHashes(key)
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain reports whether key may have been added. False means it never was.
+func (f *bloomFilter) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reset removes all keys from the filter.
+func (f *bloomFilter) reset() {
+	for i := range f.bits {
+		f.bits[i] = 0
+	}
+}
+
+// bloomHashes derives the two hashes combined into the filter's k hashes.
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	// The second hash is forced odd so it never degenerates to zero.
+	return sum, sum>>32 | sum<<32 | 1
+}
+
+// resetBloom empties the Bloom filter, if any, and adds the keys of data to it. The caller
+// must hold the write lock.
+func (s *Storage) resetBloom(data map[string]*item) {
+	if s.bloom == nil {
+		return
+	}
+	s.bloom.reset()
+	for key := range data {
+		s.bloom.add(key)
+	}
+}
+
+// lookup returns the item stored under key, using the Bloom filter, if any, to skip the
+// map for keys that were never stored. The caller must hold the lock.
+func (s *Storage) lookup(key string) (*item, bool) {
+	if s.bloom != nil && !s.bloom.mayContain(key) {
+		return nil, false
+	}
+	item, exists := s.data[key]
+	return item, exists
+}
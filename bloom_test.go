@@ -0,0 +1,69 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStorage_BloomFilter(t *testing.T) {
+	const numKeys = 1000
+	store := New(WithBloomFilter(numKeys, 0.01))
+
+	// Test no false negatives.
+	for i := 0; i < numKeys; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	for i := 0; i < numKeys; i++ {
+		if value, err := store.Get(fmt.Sprintf("key%d", i)); err != nil || value != i {
+			t.Errorf("Expected %d for key%d, got %v, %v", i, i, value, err)
+		}
+	}
+
+	// Test the false positive rate staying near the configured one.
+	falsePositives := 0
+	for i := 0; i < numKeys; i++ {
+		if store.bloom.mayContain(fmt.Sprintf("absent%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > numKeys/20 {
+		t.Errorf("Expected about 1%% false positives, but got %d of %d", falsePositives, numKeys)
+	}
+
+	// Test keys ruled out by the filter skipping the map, even if an entry is planted there.
+	store.Reset()
+	store.Set("key", "value", 0)
+	store.data["hidden"] = newItem("value", store.data["key"].expiration)
+	if _, err := store.Get("hidden"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound from the filter, but got %v", err)
+	}
+	if found, err := store.Has("hidden"); err != nil || found {
+		t.Errorf("Expected Has to report false from the filter, got %v, %v", found, err)
+	}
+	if _, err := store.Get("key"); err != nil {
+		t.Errorf("Expected key to be found after Reset, but got %v", err)
+	}
+
+	// Test restored keys being added to the filter.
+	snap := New()
+	snap.Set("restoredKey", "value", 0)
+	store.Restore(snap.Snapshot())
+	if _, err := store.Get("restoredKey"); err != nil {
+		t.Errorf("Expected restoredKey to be found, but got %v", err)
+	}
+}
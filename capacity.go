@@ -0,0 +1,175 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// FullPolicy selects what writes do when storage holds the maximum number of entries set
+// by WithMaxEntries.
+type FullPolicy int
+
+const (
+	// PolicyEvict makes room by evicting the least recently used entry. Like Redis, it
+	// approximates LRU by picking the least recently read or written of a few sampled entries.
+	PolicyEvict FullPolicy = iota
+
+	// PolicyReject makes writes of new keys fail with ErrStoreFull.
+	PolicyReject
+
+	// PolicyBlock makes writes of new keys wait until an entry is deleted or an expired entry
+	// is removed. SetContext stops waiting with the context's error when its context is done;
+	// other writes wait indefinitely. With no deletes, TTLs or running cleanup to free
+	// space, waiting writers block forever. Waiting releases the write lock, so batch writes
+	// such as SetKeys or IncrementMany are not atomic when they have to wait.
+	PolicyBlock
+)
+
+// evictionSamples is the number of entries sampled to pick one to evict.
+const evictionSamples = 8
+
+// makeRoom ensures storage has room for the keys among keys that are not stored yet,
+// applying the full policy. The caller must hold the write lock, which waiting releases.
+func (s *Storage) makeRoom(ctx context.Context, keys ...string) error {
+	if s.maxEntries <= 0 {
+		return nil
+	}
+	if s.fullPolicy == PolicyBlock && ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() {
+			s.mu.Lock()
+			s.spaceFreed.Broadcast()
+			s.mu.Unlock()
+		})
+		defer stop()
+	}
+
+	for {
+		err := s.tryMakeRoom(keys...)
+		if err == nil || s.fullPolicy != PolicyBlock || s.newKeys(keys) > s.maxEntries {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.spaceFreed.Wait()
+	}
+}
+
+// tryMakeRoom is like makeRoom, but returns ErrStoreFull rather than waiting for room.
+// The caller must hold the write lock.
+func (s *Storage) tryMakeRoom(keys ...string) error {
+	if s.maxEntries <= 0 {
+		return nil
+	}
+	if s.newKeys(keys) > s.maxEntries {
+		return ErrStoreFull
+	}
+	for len(s.data)+s.newKeys(keys) > s.maxEntries {
+		if !s.evictOne(s.fullPolicy == PolicyEvict) {
+			return ErrStoreFull
+		}
+	}
+	return nil
+}
+
+// newKeys returns the number of distinct keys among keys that are not stored.
+// The caller must hold the lock.
+func (s *Storage) newKeys(keys []string) int {
+	if len(keys) == 1 {
+		if _, exists := s.data[keys[0]]; exists {
+			return 0
+		}
+		return 1
+	}
+	missing := make(map[string]struct{})
+	for _, key := range keys {
+		if _, exists := s.data[key]; !exists {
+			missing[key] = struct{}{}
+		}
+	}
+	return len(missing)
+}
+
+// evictOne removes an expired entry among a few sampled ones or, if live is set and none
+// has expired, the least recently used of them. It reports whether an entry was removed.
+// The caller must hold the write lock.
+func (s *Storage) evictOne(live bool) bool {
+	now := s.now()
+	var victim string
+	var oldest int64
+	sampled := 0
+	for key, item := range s.data {
+		if item.isExpiredAt(now) {
+			s.notifyExpired(s.expireItem(key, item, nil))
+			return true
+		}
+		if lastUsed := atomic.LoadInt64(&item.lastUsed); victim == "" || lastUsed < oldest {
+			victim, oldest = key, lastUsed
+		}
+		if sampled++; sampled == evictionSamples {
+			break
+		}
+	}
+	if !live || victim == "" {
+		return false
+	}
+	s.notifyEvicted(victim, s.data[victim])
+	s.removeItemAs(victim, EventEvict)
+	atomic.AddUint64(&s.evictions, 1)
+	return true
+}
+
+// notifyEvicted passes the evicted item stored under key to the evict callback in the
+// background, so the callback never runs under the lock.
+func (s *Storage) notifyEvicted(key string, item *item) {
+	if s.onEvict == nil {
+		return
+	}
+	s.safeGo(func() {
+		s.evicted(key, item)
+	})
+}
+
+// notifyEvictedAll passes every item of data, which storage no longer uses, to the evict
+// callback in the background.
+func (s *Storage) notifyEvictedAll(data map[string]*item) {
+	if s.onEvict == nil || len(data) == 0 {
+		return
+	}
+	s.safeGo(func() {
+		for key, item := range data {
+			s.evicted(key, item)
+		}
+	})
+}
+
+// evicted calls the evict callback with the key and decoded value of an evicted item.
+func (s *Storage) evicted(key string, item *item) {
+	value, err := s.decode(item.value)
+	if err != nil {
+		s.logger.Printf("Remo: [Evict] decoding key %q: %v", key, err)
+	}
+	s.onEvict(key, value)
+}
+
+// markUsed records that item was read or written at now, for PolicyEvict.
+func (s *Storage) markUsed(item *item, now time.Time) {
+	if s.maxEntries > 0 {
+		atomic.StoreInt64(&item.lastUsed, now.UnixNano())
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage_PolicyEvict(t *testing.T) {
+	store := New(WithMaxEntries(3))
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	for _, key := range []string{"a", "b", "c"} {
+		store.Set(key, key, 0)
+		now = now.Add(time.Second)
+	}
+	store.Get("a")
+
+	// Test the least recently used entry being evicted.
+	if err := store.Set("d", "d", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if _, err := store.Get("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected b to be evicted, but got %v", err)
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("Expected %s to be kept, but got %v", key, err)
+		}
+	}
+	if evictions := store.Stats().Evictions; evictions != 1 {
+		t.Errorf("Expected 1 eviction, but got %d", evictions)
+	}
+
+	// Test overwriting a stored key not evicting anything.
+	if err := store.Set("a", "a2", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if len(store.data) != 3 || store.Stats().Evictions != 1 {
+		t.Errorf("Expected no eviction on overwrite, but got %d entries", len(store.data))
+	}
+
+	// Test a batch larger than the cap being rejected without evicting.
+	if err := store.SetKeys([]string{"w", "x", "y", "z"}, "value", 0); err != ErrStoreFull {
+		t.Errorf("Expected ErrStoreFull, but got %v", err)
+	}
+	if len(store.data) != 3 {
+		t.Errorf("Expected 3 entries to be kept, but got %d", len(store.data))
+	}
+}
+
+func TestStorage_PolicyReject(t *testing.T) {
+	store := New(WithMaxEntries(2), WithFullPolicy(PolicyReject))
+	store.Set("a", "a", 0)
+	store.Set("b", "b", 50*time.Millisecond)
+
+	// Test expired entries being reclaimed before rejecting.
+	time.Sleep(100 * time.Millisecond)
+	if err := store.Set("c", "c", 0); err != nil {
+		t.Errorf("Expected the expired entry to make room, but got %v", err)
+	}
+
+	// Test writes of new keys being rejected.
+	if err := store.Set("d", "d", 0); !errors.Is(err, ErrStoreFull) {
+		t.Errorf("Expected ErrStoreFull, but got %v", err)
+	}
+	if _, err := store.Increment("counter", 1, 0); !errors.Is(err, ErrStoreFull) {
+		t.Errorf("Expected ErrStoreFull from Increment, but got %v", err)
+	}
+	if err := store.Set("a", "a2", 0); err != nil {
+		t.Errorf("Expected an overwrite to succeed, but got %v", err)
+	}
+	if evictions := store.Stats().Evictions; evictions != 0 {
+		t.Errorf("Expected no evictions, but got %d", evictions)
+	}
+}
+
+func TestStorage_PolicyBlock(t *testing.T) {
+	store := New(WithMaxEntries(1), WithFullPolicy(PolicyBlock))
+	store.Set("a", "a", 0)
+
+	// Test a blocked write resuming once an entry is deleted.
+	done := make(chan error)
+	go func() {
+		done <- store.Set("b", "b", 0)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Set to block, but it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	store.Delete("a")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected Set to succeed, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Set to unblock after a delete")
+	}
+	if value, _ := store.Get("b"); value != "b" {
+		t.Errorf("Expected b to be stored, but got %v", value)
+	}
+
+	// Test a blocked write giving up when its context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := store.SetContext(ctx, "c", "c", 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestStorage_EvictCallback(t *testing.T) {
+	var mu sync.Mutex
+	closed := make(map[string]interface{})
+	store := New(WithMaxEntries(100), WithEvictCallback(func(key string, value interface{}) {
+		mu.Lock()
+		closed[key] = value
+		mu.Unlock()
+	}))
+	closedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(closed)
+	}
+
+	// Test the callback firing for every entry cleared by Reset.
+	for i := 0; i < 100; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	store.Reset()
+	waitFor(t, func() bool { return closedCount() == 100 })
+	mu.Lock()
+	if closed["key42"] != 42 {
+		t.Errorf("Expected the value of key42, but got %v", closed["key42"])
+	}
+	closed = make(map[string]interface{})
+	mu.Unlock()
+
+	// Test the callback firing for entries evicted to make room.
+	for i := 0; i < 101; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	waitFor(t, func() bool { return closedCount() == 1 })
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"sort"
+	"time"
+)
+
+// cleanupPolicy is a cleanup cadence registered with WithCleanupPolicy.
+type cleanupPolicy struct {
+	pattern  string
+	interval time.Duration
+}
+
+// cleanupClass returns the index of the most frequent cleanup policy matching key, or -1 if
+// none does. The policies are sorted by interval, so that is the first match.
+func (s *Storage) cleanupClass(key string) int {
+	for i, policy := range s.cleanupPolicies {
+		if matchGlob(policy.pattern, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// startCleanupPolicies starts the goroutine running the passes of the cleanup policies,
+// checking every shortest interval which of them are due.
+func (s *Storage) startCleanupPolicies() {
+	sort.SliceStable(s.cleanupPolicies, func(i, j int) bool {
+		return s.cleanupPolicies[i].interval < s.cleanupPolicies[j].interval
+	})
+	tick := s.cleanupPolicies[0].interval
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.policiesStop, s.policiesDone = stop, done
+	s.safeGo(func() {
+		defer close(done)
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		start := time.Now()
+		next := make([]time.Time, len(s.cleanupPolicies))
+		for i, policy := range s.cleanupPolicies {
+			next[i] = start.Add(policy.interval - tick/2)
+		}
+		for {
+			select {
+			case now := <-ticker.C:
+				for i, policy := range s.cleanupPolicies {
+					if now.Before(next[i]) {
+						continue
+					}
+					s.removeExpiredClass(i)
+					// Half a tick of slack keeps ticker jitter from delaying a pass by a whole tick.
+					next[i] = now.Add(policy.interval - tick/2)
+				}
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// stopCleanupPolicies stops the goroutine running the cleanup policies, if any, and waits
+// for it to exit.
+func (s *Storage) stopCleanupPolicies() {
+	if s.policiesStop == nil {
+		return
+	}
+	close(s.policiesStop)
+	<-s.policiesDone
+}
+
+// removeExpiredClass removes the expired items whose most frequent cleanup policy is the
+// class-th one.
+func (s *Storage) removeExpiredClass(class int) {
+	s.removeExpiredTombstones()
+	var expired []KeyValue
+	now := s.now()
+	s.mu.Lock()
+	for key, item := range s.data {
+		if s.isReclaimable(item, now) && s.cleanupClass(key) == class {
+			expired = s.expireItem(key, item, expired)
+		}
+	}
+	s.mu.Unlock()
+	s.notifyExpired(expired)
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that keys of a frequently cleaned namespace are collected before long-lived ones.
+func TestStorage_CleanupPolicy(t *testing.T) {
+	store := New(
+		WithCleanupPolicy("long:*", time.Hour),
+		WithCleanupPolicy("ephemeral:*", 10*time.Millisecond),
+		// Test the most frequent policy winning for keys matching several.
+		WithCleanupPolicy("*:both", time.Hour),
+	)
+	defer store.Close()
+
+	store.Set("ephemeral:1", "value", time.Millisecond)
+	store.Set("ephemeral:both", "value", time.Millisecond)
+	store.Set("long:1", "value", time.Millisecond)
+	store.Set("other", "value", time.Millisecond)
+
+	isStored := func(key string) bool {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		_, exists := store.data[key]
+		return exists
+	}
+	waitFor(t, func() bool { return !isStored("ephemeral:1") && !isStored("ephemeral:both") })
+	if !isStored("long:1") || !isStored("other") {
+		t.Errorf("Expected long-lived and unmatched keys to be left for later cleanup")
+	}
+	if class := store.cleanupClass("long:both"); store.cleanupPolicies[class].interval != time.Hour {
+		t.Errorf("Expected long:both to follow an hourly policy, but got %v", store.cleanupPolicies[class])
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that operations after Close fail with ErrClosed without panicking, and that
+// Close, Reset and the other mutations are harmless no-ops on closed storage.
+func TestStorage_Closed(t *testing.T) {
+	store := New(WithAutoCleanup(time.Hour), WithAsyncDelete(10, time.Hour))
+	store.Set("key", 1, 0)
+	other := New()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("Expected a second Close to return nil, but got %v", err)
+	}
+
+	ctx := context.Background()
+	build := func() (interface{}, error) {
+		t.Errorf("Expected build not to run on closed storage")
+		return nil, nil
+	}
+	operations := map[string]func() error{
+		"Get":         func() error { _, err := store.Get("key"); return err },
+		"GetDetailed": func() error { _, _, err := store.GetDetailed("key"); return err },
+		"GetContext":  func() error { _, err := store.GetContext(ctx, "key"); return err },
+		"GetWithTTL":  func() error { _, _, err := store.GetWithTTL("key"); return err },
+		"GetAndTouch": func() error { _, err := store.GetAndTouch("key", time.Minute); return err },
+		"GetString":   func() error { _, err := store.GetString("key"); return err },
+		"GetInt":      func() error { _, err := store.GetInt("key"); return err },
+		"GetToken":    func() error { _, _, err := store.GetToken("key"); return err },
+		"GetConsistent": func() error {
+			_, err := store.GetConsistent("key")
+			return err
+		},
+		"GetOrCompute": func() error {
+			_, err := store.GetOrCompute(ctx, "key", 0, func(context.Context) (interface{}, error) { return build() })
+			return err
+		},
+		"Once":              func() error { _, err := store.Once("key", build); return err },
+		"Set":               func() error { return store.Set("key", 2, 0) },
+		"SetContext":        func() error { return store.SetContext(ctx, "key", 2, 0) },
+		"SetOnce":           func() error { return store.SetOnce("newKey", 2, 0) },
+		"SetWithGeneration": func() error { _, err := store.SetWithGeneration("key", 2, 0); return err },
+		"SetIfToken":        func() error { _, err := store.SetIfToken("key", 2, 0, 1); return err },
+		"SetKeys":           func() error { return store.SetKeys([]string{"key"}, 2, 0) },
+		"SetWithDependency": func() error { return store.SetWithDependency("newKey", 2, 0, "key") },
+		"MergeMap": func() error {
+			return store.MergeMap(map[string]interface{}{"key": 2}, 0, func(key string, oldVal, newVal interface{}) interface{} { return newVal })
+		},
+		"Warm": func() error {
+			return store.Warm(ctx, func(yield func(string, interface{}, time.Duration) error) error { return yield("key", 2, 0) })
+		},
+		"LoadLines":           func() error { _, err := store.LoadLines(strings.NewReader("key=2\n"), 0); return err },
+		"Has":                 func() error { _, err := store.Has("key"); return err },
+		"CreatedAt":           func() error { _, err := store.CreatedAt("key"); return err },
+		"TTL":                 func() error { _, err := store.TTL("key"); return err },
+		"Touch":               func() error { return store.Touch("key", time.Minute) },
+		"CompareAndSwap":      func() error { _, err := store.CompareAndSwap("key", 1, 2); return err },
+		"DeleteIf":            func() error { _, err := store.DeleteIf("key", 1); return err },
+		"Increment":           func() error { _, err := store.Increment("key", 1, 0); return err },
+		"IncrementMany":       func() error { _, err := store.IncrementMany(map[string]int64{"key": 1}, 0); return err },
+		"DecrAndDeleteIfZero": func() error { _, _, err := store.DecrAndDeleteIfZero("key"); return err },
+		"UpdateInPlace":       func() error { return store.UpdateInPlace("key", func(interface{}) error { return nil }) },
+		"Toggle":              func() error { _, err := store.Toggle("flag", 0); return err },
+		"RateLimit":           func() error { _, _, err := store.RateLimit("limit", time.Minute, 1); return err },
+		"RingPush":            func() error { return store.RingPush("ring", 2, 3, 0) },
+		"RingGet":             func() error { _, err := store.RingGet("ring"); return err },
+		"RenameMerge":         func() error { return store.RenameMerge("key", "otherKey", true) },
+		"MoveTo":              func() error { return store.MoveTo(other, "key") },
+	}
+	for name, operation := range operations {
+		if err := operation(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s: expected %v, but got %v", name, ErrClosed, err)
+		}
+	}
+
+	if _, errs := store.GetSlice([]string{"key"}); !errors.Is(errs[0], ErrClosed) {
+		t.Errorf("GetSlice: expected %v, but got %v", ErrClosed, errs[0])
+	}
+	var value interface{}
+	if store.GetInto("key", &value) {
+		t.Errorf("GetInto: expected no value, but got %v", value)
+	}
+	if found := store.HasMany([]string{"key"}); found["key"] {
+		t.Errorf("HasMany: expected nothing found")
+	}
+
+	// Test mutations without an error result doing nothing.
+	store.Delete("key")
+	store.DeleteContext(ctx, "key")
+	store.DeleteMany([]string{"key"})
+	store.Reset()
+	store.SoftReset()
+	store.PurgeExpired()
+	store.Compact()
+	store.Restore(other.Snapshot())
+	store.StartCleanup(time.Hour)
+	if store.Len() != 1 {
+		t.Errorf("Expected the entry to be left alone, but got %d entries", store.Len())
+	}
+	if metrics := store.MetricsSnapshot(); !metrics.Closed || metrics.CleanupRunning {
+		t.Errorf("Expected closed storage without cleanup, but got %+v", metrics)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "time"
+
+// coalesceWrite buffers item as the latest value of key for the coalescing goroutine. It
+// reports false if storage was closed, in which case the caller must store the item itself.
+func (s *Storage) coalesceWrite(key string, item *item) bool {
+	s.coalesceMu.Lock()
+	defer s.coalesceMu.Unlock()
+	if s.coalesceClosed {
+		return false
+	}
+	if replaced, exists := s.pendingWrites[key]; exists {
+		s.recycleItem(replaced)
+	}
+	s.pendingWrites[key] = item
+	return true
+}
+
+// dropPendingWrite discards the buffered write of key, if any, so a later flush does not
+// bring back a deleted key. The caller must hold the lock, read or write, so a flush
+// cannot take the write between the removal and the drop.
+func (s *Storage) dropPendingWrite(key string) {
+	if s.coalesce == nil {
+		return
+	}
+	s.coalesceMu.Lock()
+	if dropped, exists := s.pendingWrites[key]; exists {
+		s.recycleItem(dropped)
+		delete(s.pendingWrites, key)
+	}
+	s.coalesceMu.Unlock()
+}
+
+// dropPendingWrites discards every buffered write. The caller must hold the write lock.
+func (s *Storage) dropPendingWrites() {
+	if s.coalesce == nil {
+		return
+	}
+	s.coalesceMu.Lock()
+	s.pendingWrites = make(map[string]*item)
+	s.coalesceMu.Unlock()
+}
+
+// startCoalescing starts the goroutine storing buffered writes every interval.
+func (s *Storage) startCoalescing(interval time.Duration) {
+	s.pendingWrites = make(map[string]*item)
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.coalesceStop, s.coalesceDone = stop, done
+	s.safeGo(func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.flushWrites()
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// stopCoalescing stops the coalescing goroutine and stores the writes still buffered.
+// Later writes are stored synchronously.
+func (s *Storage) stopCoalescing() {
+	if s.coalesce == nil {
+		return
+	}
+
+	s.coalesceMu.Lock()
+	if s.coalesceClosed {
+		s.coalesceMu.Unlock()
+		return
+	}
+	s.coalesceClosed = true
+	s.coalesceMu.Unlock()
+
+	close(s.coalesceStop)
+	<-s.coalesceDone
+	s.flushWrites()
+}
+
+// flushWrites takes and stores the buffered writes under a single write lock, so a removal
+// dropping a buffered write cannot run between the two. Writes that fail, such as writes
+// to an immutable key or to full storage, are logged and dropped.
+func (s *Storage) flushWrites() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.coalesceMu.Lock()
+	pending := s.pendingWrites
+	s.pendingWrites = make(map[string]*item)
+	s.coalesceMu.Unlock()
+
+	for key, item := range pending {
+		if err := s.tryMakeRoom(key); err != nil {
+			s.logger.Printf("Remo: [Coalesce] dropping write of key %q: %v", key, err)
+			continue
+		}
+		if err := s.checkWritable(key); err != nil {
+			s.logger.Printf("Remo: [Coalesce] dropping write of key %q: %v", key, err)
+			continue
+		}
+		s.storeItem(key, item)
+	}
+}
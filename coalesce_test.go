@@ -0,0 +1,105 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorage_WriteCoalescing(t *testing.T) {
+	store := New(WithWriteCoalescing(10*time.Millisecond, func(key string) bool {
+		return key == "progress"
+	}))
+	events, _ := store.EventsFiltered(EventSet)
+
+	// Hammer a coalesced key.
+	const sets = 10000
+	for i := 0; i <= sets; i++ {
+		if err := store.Set("progress", i, 0); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	store.Close()
+
+	// Test the map being updated far fewer times than Set was called.
+	updates := 0
+	for len(events) > 0 {
+		<-events
+		updates++
+	}
+	if updates == 0 || updates > sets/20 {
+		t.Errorf("Expected a few map updates, but got %d", updates)
+	}
+
+	// Test the final value being stored.
+	if item, exists := store.data["progress"]; !exists || item.value != sets {
+		t.Errorf("Expected %d, got %v", sets, item)
+	}
+}
+
+func TestStorage_WriteCoalescingDelete(t *testing.T) {
+	store := New(WithWriteCoalescing(time.Hour, func(key string) bool { return true }))
+	defer store.Close()
+
+	// Test buffered writes staying invisible until flushed.
+	store.Set("key", "value", 0)
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound before the flush, but got %v", err)
+	}
+
+	// Test Delete discarding the buffered write.
+	store.Delete("key")
+	store.flushWrites()
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after Delete, but got %v", err)
+	}
+
+	// Test validation errors being returned by Set.
+	if err := store.Set("", "value", 0); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, but got %v", err)
+	}
+}
+
+// Test that every removal path, not only Delete, discards the buffered write of the key.
+func TestStorage_WriteCoalescingDeletePaths(t *testing.T) {
+	store := New(WithWriteCoalescing(time.Hour, func(key string) bool { return true }))
+	defer store.Close()
+
+	store.Set("deleteIf", "old", 0)
+	store.Set("counter", int64(1), 0)
+	store.flushWrites()
+	store.Set("deleteMany", "value", 0)
+	store.Set("deleteIf", "new", 0)
+	store.Set("counter", int64(5), 0)
+
+	if removed := store.DeleteMany([]string{"deleteMany"}); len(removed) != 0 {
+		t.Errorf("Expected no stored key to be removed, but got %v", removed)
+	}
+	if deleted, err := store.DeleteIf("deleteIf", "old"); err != nil || !deleted {
+		t.Errorf("Expected DeleteIf to delete, but got %v, %v", deleted, err)
+	}
+	if _, deleted, err := store.DecrAndDeleteIfZero("counter"); err != nil || !deleted {
+		t.Errorf("Expected DecrAndDeleteIfZero to delete, but got %v, %v", deleted, err)
+	}
+
+	store.flushWrites()
+	for _, key := range []string{"deleteMany", "deleteIf", "counter"} {
+		if _, err := store.Get(key); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound for %s after the flush, but got %v", key, err)
+		}
+	}
+}
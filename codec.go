@@ -0,0 +1,104 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Codec converts values to bytes and back, for storage created with WithSerializedValues.
+type Codec interface {
+	Encode(value interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+// GobCodec is a Codec based on encoding/gob. Concrete types other than the basic ones,
+// []interface{}, map[string]interface{} and []time.Time must be registered with gob.Register before
+// they are stored.
+type GobCodec struct{}
+
+func init() {
+	// Rings are stored as []interface{}, hashes as map[string]interface{} and rate
+	// windows as []time.Time.
+	gob.Register([]interface{}(nil))
+	gob.Register(map[string]interface{}(nil))
+	gob.Register([]time.Time(nil))
+}
+
+// Encode encodes value with gob.
+func (GobCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a value encoded by Encode.
+func (GobCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// encode converts a value to its stored form, which is itself unless a codec is configured.
+func (s *Storage) encode(value interface{}) (interface{}, error) {
+	if s.rejectNonSerializable && !isSerializable(value) {
+		return nil, ErrNonSerializable
+	}
+	if s.codec == nil {
+		return value, nil
+	}
+	return s.codec.Encode(value)
+}
+
+// isSerializable reports whether value is not a function, channel or unsafe pointer. Only
+// the value itself is checked, not the values it contains.
+func isSerializable(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
+
+// decode converts a stored value back to the value that was set. It returns ErrWrongType,
+// rather than panicking, if the stored value was not encoded by a codec.
+func (s *Storage) decode(stored interface{}) (interface{}, error) {
+	if s.codec == nil {
+		return stored, nil
+	}
+	data, ok := stored.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: stored value is %T, not encoded bytes", ErrWrongType, stored)
+	}
+	return s.codec.Decode(data)
+}
+
+// reencode converts a value stored by src to the form s stores it in, in case the two
+// storages use different codecs.
+func (s *Storage) reencode(src *Storage, stored interface{}) (interface{}, error) {
+	value, err := src.decode(stored)
+	if err != nil {
+		return nil, err
+	}
+	return s.encode(value)
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestStorage_SerializedValues(t *testing.T) {
+	store := New(WithSerializedValues(GobCodec{}))
+
+	err := store.Set("key", "value", 0)
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if _, ok := store.data["key"].value.([]byte); !ok {
+		t.Errorf("Expected the value to be stored as bytes")
+	}
+
+	value, err := store.Get("key")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if value != "value" {
+		t.Errorf("Expected value, but got %v", value)
+	}
+
+	// Test values being compared and swapped in their decoded form.
+	swapped, err := store.CompareAndSwap("key", "value", "newValue")
+	if err != nil || !swapped {
+		t.Errorf("Expected swap, got %v, %v", swapped, err)
+	}
+	values, errs := store.GetSlice([]string{"key"})
+	if values[0] != "newValue" || errs[0] != nil {
+		t.Errorf("Expected newValue, got %v, %v", values[0], errs[0])
+	}
+
+	// Test rings being kept encoded.
+	store.RingPush("ring", 1, 2, 0)
+	store.RingPush("ring", 2, 2, 0)
+	ring, err := store.RingGet("ring")
+	if err != nil || !reflect.DeepEqual(ring, []interface{}{1, 2}) {
+		t.Errorf("Expected [1 2], got %v, %v", ring, err)
+	}
+
+	// Test values the codec cannot encode being rejected.
+	if err := store.Set("funcKey", func() {}, 0); err == nil {
+		t.Errorf("Expected an encoding error")
+	}
+	if _, err := store.Get("funcKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+
+	// Test a stored value that was never encoded being reported rather than panicking.
+	if _, err := store.decode("raw"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, but got %v", err)
+	}
+}
+
+func TestStorage_RejectNonSerializable(t *testing.T) {
+	store := New(WithRejectNonSerializable())
+
+	// Test functions, channels and unsafe pointers being rejected.
+	for _, value := range []interface{}{func() {}, make(chan int), unsafe.Pointer(nil)} {
+		if err := store.Set("key", value, 0); !errors.Is(err, ErrNonSerializable) {
+			t.Errorf("Expected ErrNonSerializable for %T, but got %v", value, err)
+		}
+	}
+	if err := store.Set("key", []int{1}, 0); err != nil {
+		t.Errorf("Expected a slice to be accepted, but got %v", err)
+	}
+
+	// Test functions being accepted without the option.
+	store = New()
+	if err := store.Set("key", func() {}, 0); err != nil {
+		t.Errorf("Expected a function to be accepted, but got %v", err)
+	}
+}
+
+// gcRecord is a pointer-rich value used to measure garbage collection cost.
+type gcRecord struct {
+	Name string
+	Tags []string
+}
+
+// BenchmarkGCPause measures the cost of a full garbage collection with 1M stored values,
+// kept as pointer-rich values or serialized to bytes.
+func BenchmarkGCPause(b *testing.B) {
+	const numEntries = 1000000
+	gob.Register(gcRecord{})
+
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Pointers", nil},
+		{"Serialized", []Option{WithSerializedValues(GobCodec{})}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			store := New(mode.opts...)
+			for i := 0; i < numEntries; i++ {
+				record := gcRecord{Name: fmt.Sprintf("record%d", i), Tags: []string{"a", "b"}}
+				if err := store.Set(fmt.Sprintf("key%d", i), record, 0); err != nil {
+					b.Fatalf("Set() failed: %v", err)
+				}
+			}
+			runtime.GC()
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+			}
+
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "pause-ns/op")
+			runtime.KeepAlive(store)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+// MapLoadFactor reports the number of entries against the estimated capacity of the
+// underlying map. Go maps never shrink and do not expose their capacity, so the capacity
+// is estimated as the peak number of entries since the map was last allocated by New,
+// Reset, Restore or Compact. A low entries/capacity ratio after heavy deletion means
+// Compact would release memory.
+func (s *Storage) MapLoadFactor() (entries, capacity int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data), s.peakLen
+}
+
+// Compact copies the live entries into a freshly allocated map, releasing the memory held
+// by the old one. Expired entries past the stale window are dropped along the way.
+func (s *Storage) Compact() {
+	if s.isClosed() {
+		return
+	}
+	var expired []KeyValue
+	now := s.now()
+	s.mu.Lock()
+	data := make(map[string]*item, len(s.data))
+	for key, item := range s.data {
+		if s.isReclaimable(item, now) {
+			expired = s.expireItem(key, item, expired)
+		} else {
+			data[key] = item
+		}
+	}
+	s.data = data
+	s.peakLen = len(data)
+	s.mu.Unlock()
+	s.notifyExpired(expired)
+}
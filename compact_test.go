@@ -0,0 +1,54 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStorage_MapLoadFactor(t *testing.T) {
+	store := New()
+
+	// Grow the storage.
+	for i := 0; i < 1000; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	entries, capacity := store.MapLoadFactor()
+	if entries != 1000 || capacity != 1000 {
+		t.Errorf("Expected 1000 entries and capacity, but got %d and %d", entries, capacity)
+	}
+
+	// Shrink the storage and test the poor load factor being reported.
+	for i := 0; i < 990; i++ {
+		store.Delete(fmt.Sprintf("key%d", i))
+	}
+	entries, capacity = store.MapLoadFactor()
+	if entries != 10 || capacity != 1000 {
+		t.Errorf("Expected 10 entries and capacity 1000, but got %d and %d", entries, capacity)
+	}
+
+	// Test Compact restoring a full load factor without losing entries.
+	store.Compact()
+	entries, capacity = store.MapLoadFactor()
+	if entries != 10 || capacity != 10 {
+		t.Errorf("Expected 10 entries and capacity 10, but got %d and %d", entries, capacity)
+	}
+	for i := 990; i < 1000; i++ {
+		if _, err := store.Get(fmt.Sprintf("key%d", i)); err != nil {
+			t.Errorf("Get() failed after Compact: %v", err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// CompareAndSwap replaces the value stored under key with new if the current value equals old,
+// keeping the key's expiration. It reports whether the swap happened.
+func (s *Storage) CompareAndSwap(key string, old, new interface{}) (swapped bool, err error) {
+	defer wrapOpError("CompareAndSwap", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	stored, err := s.encode(new)
+	if err != nil {
+		return false, err
+	}
+	if err := s.checkValueSize(stored); err != nil {
+		return false, err
+	}
+
+	if err := s.lock(); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+
+	item, exists := s.data[key]
+	if !exists {
+		return false, keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		return false, keyExpired(key)
+	}
+	if item.immutable {
+		return false, ErrImmutable
+	}
+
+	current, err := s.decode(item.value)
+	if err != nil {
+		return false, err
+	}
+	equal, err := s.equal(current, old)
+	if err != nil || !equal {
+		return false, err
+	}
+	s.storeItem(key, newItem(stored, item.expiration))
+	return true, nil
+}
+
+// DeleteIf removes key only if its current value equals expected, so an invalidation does
+// not clobber a concurrent update. It reports whether the key was deleted.
+func (s *Storage) DeleteIf(key string, expected interface{}) (deleted bool, err error) {
+	defer wrapOpError("DeleteIf", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	if err := s.lock(); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+
+	item, exists := s.data[key]
+	if !exists {
+		return false, keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		return false, keyExpired(key)
+	}
+
+	current, err := s.decode(item.value)
+	if err != nil {
+		return false, err
+	}
+	equal, err := s.equal(current, expected)
+	if err != nil || !equal {
+		return false, err
+	}
+	s.dropPendingWrite(key)
+	s.removeItem(key)
+	return true, nil
+}
+
+// equal compares two values with the configured equality, falling back to ==.
+func (s *Storage) equal(a, b interface{}) (bool, error) {
+	if s.equality != nil {
+		return s.equality(a, b), nil
+	}
+	if !isComparable(a) || !isComparable(b) {
+		return false, ErrNotComparable
+	}
+	return a == b, nil
+}
+
+// isComparable reports whether comparing v with == is safe from panics.
+func isComparable(v interface{}) bool {
+	return v == nil || reflect.ValueOf(v).Comparable()
+}
+
+// GetToken returns the live value stored under key along with an opaque token identifying
+// it, for optimistic concurrency with SetIfToken. Every write of a value to the key,
+// including overwriting it with an equal value, gives it a new token, while Touch keeps it.
+func (s *Storage) GetToken(key string) (value interface{}, token uint64, err error) {
+	defer wrapOpError("GetToken", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return nil, 0, err
+	}
+	if err := s.rlock(); err != nil {
+		return nil, 0, err
+	}
+	item, exists := s.data[key]
+	if exists {
+		token = item.version
+	}
+	s.mu.RUnlock()
+
+	now := s.now()
+	if !exists {
+		atomic.AddUint64(&s.misses, 1)
+		return nil, 0, keyNotFound(key)
+	}
+	if item.isExpiredAt(now) {
+		atomic.AddUint64(&s.misses, 1)
+		s.removeExpiredItem(key, item)
+		return nil, 0, keyExpired(key)
+	}
+	value, err = s.hit(item, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, token, nil
+}
+
+// SetIfToken sets a key-value pair like Set, but only if the value stored under key still
+// has the token returned by GetToken, that is, if it was not written since. It reports
+// whether the value was set. Unlike CompareAndSwap, it works for values that cannot be
+// compared. A missing or expired key returns ErrKeyNotFound or ErrKeyExpired.
+func (s *Storage) SetIfToken(key string, value interface{}, ttl time.Duration, token uint64) (set bool, err error) {
+	defer wrapOpError("SetIfToken", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	item, err := s.prepareItem(key, value, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.lock(); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	current, exists := s.data[key]
+	if !exists {
+		return false, keyNotFound(key)
+	}
+	if current.isExpiredAt(s.now()) {
+		return false, keyExpired(key)
+	}
+	if current.immutable {
+		return false, ErrImmutable
+	}
+	if current.version != token {
+		return false, nil
+	}
+	s.storeItem(key, item)
+	return true, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStorage_CompareAndSwap(t *testing.T) {
+	store := New()
+	store.Set("key", "oldValue", time.Minute)
+	expiration := store.data["key"].expiration
+
+	// Test a mismatch leaving the value untouched.
+	swapped, err := store.CompareAndSwap("key", "otherValue", "newValue")
+	if err != nil || swapped {
+		t.Errorf("Expected no swap on mismatch, got %v, %v", swapped, err)
+	}
+
+	// Test a match swapping the value and keeping the expiration.
+	swapped, err = store.CompareAndSwap("key", "oldValue", "newValue")
+	if err != nil || !swapped {
+		t.Fatalf("Expected swap on match, got %v, %v", swapped, err)
+	}
+	value, _ := store.Get("key")
+	if value != "newValue" {
+		t.Errorf("Expected newValue, but got %v", value)
+	}
+	if !store.data["key"].expiration.Equal(expiration) {
+		t.Errorf("Expected expiration to be kept")
+	}
+
+	// Test a missing key.
+	_, err = store.CompareAndSwap("missingKey", "oldValue", "newValue")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+
+	// Test an expired key.
+	store.Set("expiredKey", "oldValue", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	_, err = store.CompareAndSwap("expiredKey", "oldValue", "newValue")
+	if !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
+
+func TestStorage_CompareAndSwapNotComparable(t *testing.T) {
+	store := New()
+	store.Set("sliceKey", []string{"a"}, 0)
+
+	// Test the default equality guarding against non-comparable values.
+	swapped, err := store.CompareAndSwap("sliceKey", []string{"a"}, []string{"b"})
+	if !errors.Is(err, ErrNotComparable) || swapped {
+		t.Errorf("Expected ErrNotComparable, got %v, %v", swapped, err)
+	}
+
+	// Test a struct holding a non-comparable value in an interface field.
+	type wrapper struct{ v interface{} }
+	store.Set("structKey", wrapper{v: map[string]int{}}, 0)
+	_, err = store.CompareAndSwap("structKey", wrapper{v: 1}, wrapper{v: 2})
+	if !errors.Is(err, ErrNotComparable) {
+		t.Errorf("Expected ErrNotComparable, but got %v", err)
+	}
+}
+
+func TestStorage_CompareAndSwapWithEquality(t *testing.T) {
+	store := New(WithEquality(reflect.DeepEqual))
+	store.Set("sliceKey", []string{"a"}, 0)
+
+	swapped, err := store.CompareAndSwap("sliceKey", []string{"x"}, []string{"b"})
+	if err != nil || swapped {
+		t.Errorf("Expected no swap on mismatch, got %v, %v", swapped, err)
+	}
+
+	swapped, err = store.CompareAndSwap("sliceKey", []string{"a"}, []string{"b"})
+	if err != nil || !swapped {
+		t.Fatalf("Expected swap on match, got %v, %v", swapped, err)
+	}
+	value, _ := store.Get("sliceKey")
+	if !reflect.DeepEqual(value, []string{"b"}) {
+		t.Errorf("Expected [b], but got %v", value)
+	}
+
+	// Test comparable values still working with a custom equality.
+	store.Set("intKey", 1, 0)
+	swapped, _ = store.CompareAndSwap("intKey", 1, 2)
+	if !swapped {
+		t.Errorf("Expected swap for comparable values")
+	}
+}
+
+func TestStorage_DeleteIf(t *testing.T) {
+	store := New()
+	store.Set("key", "value", 0)
+
+	// Test a mismatch preserving the key.
+	deleted, err := store.DeleteIf("key", "otherValue")
+	if err != nil || deleted {
+		t.Errorf("Expected no delete on mismatch, got %v, %v", deleted, err)
+	}
+	if _, err := store.Get("key"); err != nil {
+		t.Errorf("Expected key to be preserved, but got %v", err)
+	}
+
+	// Test a match deleting the key.
+	deleted, err = store.DeleteIf("key", "value")
+	if err != nil || !deleted {
+		t.Errorf("Expected delete on match, got %v, %v", deleted, err)
+	}
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+
+	// Test a missing key.
+	deleted, err = store.DeleteIf("key", "value")
+	if !errors.Is(err, ErrKeyNotFound) || deleted {
+		t.Errorf("Expected ErrKeyNotFound, got %v, %v", deleted, err)
+	}
+
+	// Test an expired key.
+	store.Set("expiredKey", "value", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	deleted, err = store.DeleteIf("expiredKey", "value")
+	if !errors.Is(err, ErrKeyExpired) || deleted {
+		t.Errorf("Expected ErrKeyExpired, got %v, %v", deleted, err)
+	}
+
+	// Test the configurable equality being respected.
+	store = New(WithEquality(reflect.DeepEqual))
+	store.Set("sliceKey", []int{1, 2}, 0)
+	deleted, err = store.DeleteIf("sliceKey", []int{1, 2})
+	if err != nil || !deleted {
+		t.Errorf("Expected delete with custom equality, got %v, %v", deleted, err)
+	}
+}
+
+func TestStorage_SetIfToken(t *testing.T) {
+	store := New()
+	store.Set("key", []int{1}, 0)
+
+	// Test a write with the current token succeeding.
+	value, token, err := store.GetToken("key")
+	if err != nil || !reflect.DeepEqual(value, []int{1}) {
+		t.Fatalf("Expected [1], got %v, %v", value, err)
+	}
+	if set, err := store.SetIfToken("key", []int{2}, 0, token); err != nil || !set {
+		t.Errorf("Expected the write to succeed, got %v, %v", set, err)
+	}
+
+	// Test a stale token being rejected.
+	if set, err := store.SetIfToken("key", []int{3}, 0, token); err != nil || set {
+		t.Errorf("Expected the stale token to be rejected, got %v, %v", set, err)
+	}
+	if value, _ := store.Get("key"); !reflect.DeepEqual(value, []int{2}) {
+		t.Errorf("Expected [2], but got %v", value)
+	}
+
+	// Test every write changing the token, even of an equal value, and Touch keeping it.
+	_, token, _ = store.GetToken("key")
+	store.Touch("key", time.Minute)
+	if _, touched, _ := store.GetToken("key"); touched != token {
+		t.Errorf("Expected Touch to keep the token %d, but got %d", token, touched)
+	}
+	store.Set("key", []int{2}, 0)
+	if _, rewritten, _ := store.GetToken("key"); rewritten == token {
+		t.Errorf("Expected a new token after a write, but got %d again", rewritten)
+	}
+
+	// Test missing keys.
+	if _, _, err := store.GetToken("missingKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	if _, err := store.SetIfToken("missingKey", 1, 0, token); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+}
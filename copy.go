@@ -0,0 +1,55 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "reflect"
+
+// readValue returns the value of item as handed to readers: decoded, and shallow-copied if
+// it is a slice or map and WithContainerCopy is set.
+func (s *Storage) readValue(item *item) (interface{}, error) {
+	value, err := s.decode(item.value)
+	if err != nil || !s.copyContainers || s.codec != nil {
+		// Decoded values are already fresh copies.
+		return value, err
+	}
+	return copyContainer(value), nil
+}
+
+// copyContainer returns a shallow copy of value if it is a non-nil slice or map, and value
+// itself otherwise.
+func copyContainer(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		return copied.Interface()
+	case reflect.Map:
+		if v.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return copied.Interface()
+	default:
+		return value
+	}
+}
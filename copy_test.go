@@ -0,0 +1,61 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStorage_ContainerCopy(t *testing.T) {
+	store := New(WithContainerCopy())
+	stored := make([]int, 2, 10)
+	stored[0], stored[1] = 1, 2
+	store.Set("slice", stored, 0)
+	store.Set("map", map[string]int{"a": 1}, 0)
+	store.Set("int", 42, 0)
+
+	// Test appending to a returned slice not affecting the stored one, even with spare capacity.
+	value, _ := store.Get("slice")
+	returned := append(value.([]int), 3)
+	returned[0] = 100
+	if value, _ := store.Get("slice"); !reflect.DeepEqual(value, []int{1, 2}) {
+		t.Errorf("Expected the stored slice to be unaffected, but got %v", value)
+	}
+	if stored[:3][2] != 0 {
+		t.Errorf("Expected the stored slice's spare capacity to be unaffected")
+	}
+
+	// Test modifying a returned map not affecting the stored one.
+	value, _ = store.Get("map")
+	value.(map[string]int)["b"] = 2
+	if value, _ := store.Get("map"); !reflect.DeepEqual(value, map[string]int{"a": 1}) {
+		t.Errorf("Expected the stored map to be unaffected, but got %v", value)
+	}
+
+	// Test scalars being returned as stored.
+	if value, _ := store.Get("int"); value != 42 {
+		t.Errorf("Expected 42, but got %v", value)
+	}
+
+	// Test containers being shared without the option.
+	store = New()
+	store.Set("slice", stored, 0)
+	value, _ = store.Get("slice")
+	value.([]int)[0] = 100
+	if stored[0] != 100 {
+		t.Errorf("Expected the stored slice to be shared without the option")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Increment atomically adds delta to the integer stored under key and returns the result,
+// stored as an int64. A missing or expired key counts as 0 and is created with the given
+// TTL; an existing key keeps its expiration. It returns ErrNotAnInteger if key holds a
+// value that is not a signed integer.
+func (s *Storage) Increment(key string, delta int64, ttl time.Duration) (result int64, err error) {
+	defer wrapOpError("Increment", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return 0, err
+	}
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return 0, err
+	}
+
+	if err := s.lock(); err != nil {
+		return 0, err
+	}
+	defer s.mu.Unlock()
+	return s.increment(key, delta, ttl)
+}
+
+// IncrementMany applies every delta like Increment under a single write lock and returns
+// the resulting values. Keys that cannot be incremented are left out of the results, and
+// their errors are joined into the returned error.
+func (s *Storage) IncrementMany(deltas map[string]int64, ttl time.Duration) (map[string]int64, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := s.validateTTL(ttl); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]int64, len(deltas))
+	var errs []error
+
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	for key, delta := range deltas {
+		if key == "" {
+			errs = append(errs, ErrEmptyKey)
+			continue
+		}
+		value, err := s.increment(key, delta, ttl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", key, err))
+			continue
+		}
+		results[key] = value
+	}
+	return results, errors.Join(errs...)
+}
+
+// Counter is a handle on an integer counter stored under a key, created with
+// Storage.Counter. Its methods are safe for concurrent use and operate atomically on the
+// stored entry, so Counters for the same key share their state through the storage, as do
+// Increment and Get calls on the key. Failures, such as the key holding a value that is
+// not an integer, make the methods return 0; use Increment to see the error.
+type Counter struct {
+	store *Storage
+	key   string
+	ttl   time.Duration
+}
+
+// Counter returns a handle on the counter stored under key. The TTL applies whenever the
+// counter is created, on its first increment or the first one after it expired or was reset.
+func (s *Storage) Counter(key string, ttl time.Duration) *Counter {
+	return &Counter{store: s, key: key, ttl: ttl}
+}
+
+// Inc adds 1 to the counter and returns the result.
+func (c *Counter) Inc() int64 {
+	return c.Add(1)
+}
+
+// Add adds n to the counter and returns the result.
+func (c *Counter) Add(n int64) int64 {
+	value, err := c.store.Increment(c.key, n, c.ttl)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// Get returns the value of the counter, 0 if it is missing or expired.
+func (c *Counter) Get() int64 {
+	value, err := c.store.Get(c.key)
+	if err != nil {
+		return 0
+	}
+	n, _ := toInt64(value)
+	return n
+}
+
+// Reset deletes the counter, so it starts again from 0 with a fresh TTL.
+func (c *Counter) Reset() {
+	c.store.Delete(c.key)
+}
+
+// increment adds delta to the integer stored under key. The caller must hold the write lock.
+func (s *Storage) increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	if err := s.makeRoom(context.Background(), key); err != nil {
+		return 0, err
+	}
+	if err := s.checkWritable(key); err != nil {
+		return 0, err
+	}
+
+	var base int64
+	var expiration time.Time
+	if item, exists := s.data[key]; exists && !item.isExpiredAt(s.now()) {
+		current, err := s.decode(item.value)
+		if err != nil {
+			return 0, err
+		}
+		var ok bool
+		if base, ok = toInt64(current); !ok {
+			return 0, ErrNotAnInteger
+		}
+		expiration = item.expiration
+	} else {
+		ttl, err := s.resolveTTL(key, base+delta, ttl)
+		if err != nil {
+			return 0, err
+		}
+		expiration = s.calculateExpiration(ttl)
+	}
+
+	result := base + delta
+	stored, err := s.encode(result)
+	if err != nil {
+		return 0, err
+	}
+	s.storeItem(key, newItem(stored, expiration))
+	return result, nil
+}
+
+// DecrAndDeleteIfZero decrements the integer stored under key, keeping its expiration, and
+// deletes the key if the result is zero or less, all under the write lock. It suits entries
+// used as reference counts, which must go away with their last reference. It returns the
+// new value and whether the key was deleted. A missing or expired key returns
+// ErrKeyNotFound or ErrKeyExpired, and a value that is not an integer ErrNotAnInteger.
+func (s *Storage) DecrAndDeleteIfZero(key string) (newValue int64, deleted bool, err error) {
+	defer wrapOpError("DecrAndDeleteIfZero", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return 0, false, err
+	}
+	if err := s.lock(); err != nil {
+		return 0, false, err
+	}
+	defer s.mu.Unlock()
+
+	item, exists := s.data[key]
+	if !exists {
+		return 0, false, keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		return 0, false, keyExpired(key)
+	}
+	if item.immutable {
+		return 0, false, ErrImmutable
+	}
+	current, err := s.decode(item.value)
+	if err != nil {
+		return 0, false, err
+	}
+	base, ok := toInt64(current)
+	if !ok {
+		return 0, false, ErrNotAnInteger
+	}
+
+	newValue = base - 1
+	if newValue <= 0 {
+		s.dropPendingWrite(key)
+		s.removeItem(key)
+		return newValue, true, nil
+	}
+	stored, err := s.encode(newValue)
+	if err != nil {
+		return 0, false, err
+	}
+	s.storeItem(key, newItem(stored, item.expiration))
+	return newValue, false, nil
+}
+
+// toInt64 converts a signed integer of any size to an int64.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
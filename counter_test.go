@@ -0,0 +1,194 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage_Increment(t *testing.T) {
+	store := New()
+
+	// Test a missing key counting as 0.
+	value, err := store.Increment("counter", 5, time.Minute)
+	if err != nil || value != 5 {
+		t.Fatalf("Expected 5, got %v, %v", value, err)
+	}
+	expiration := store.data["counter"].expiration
+
+	// Test an existing key keeping its expiration.
+	value, err = store.Increment("counter", -2, time.Hour)
+	if err != nil || value != 3 {
+		t.Errorf("Expected 3, got %v, %v", value, err)
+	}
+	if !store.data["counter"].expiration.Equal(expiration) {
+		t.Errorf("Expected the expiration to be kept")
+	}
+
+	// Test integers of other sizes being accepted.
+	store.Set("intKey", 10, 0)
+	value, err = store.Increment("intKey", 1, 0)
+	if err != nil || value != 11 {
+		t.Errorf("Expected 11, got %v, %v", value, err)
+	}
+
+	// Test a non-integer value.
+	store.Set("stringKey", "value", 0)
+	if _, err := store.Increment("stringKey", 1, 0); !errors.Is(err, ErrNotAnInteger) {
+		t.Errorf("Expected ErrNotAnInteger, but got %v", err)
+	}
+}
+
+func TestStorage_IncrementMany(t *testing.T) {
+	store := New()
+	store.Set("views:/", int64(10), 0)
+	store.Set("views:/broken", "value", 0)
+
+	results, err := store.IncrementMany(map[string]int64{
+		"views:/":       1,
+		"views:/about":  2,
+		"views:/broken": 3,
+	}, 0)
+
+	expected := map[string]int64{"views:/": 11, "views:/about": 2}
+	if len(results) != len(expected) {
+		t.Errorf("Expected %v, but got %v", expected, results)
+	}
+	for key, value := range expected {
+		if results[key] != value {
+			t.Errorf("Expected %d for %s, but got %d", value, key, results[key])
+		}
+	}
+
+	// Test per-key errors being collected.
+	if !errors.Is(err, ErrNotAnInteger) {
+		t.Errorf("Expected ErrNotAnInteger, but got %v", err)
+	}
+	value, _ := store.Get("views:/broken")
+	if value != "value" {
+		t.Errorf("Expected the non-integer value to be untouched, but got %v", value)
+	}
+}
+
+// BenchmarkIncrement measures incrementing 100 counters one call at a time, concurrently.
+func BenchmarkIncrement(b *testing.B) {
+	store := New()
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("counter%d", i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for _, key := range keys {
+				store.Increment(key, 1, 0)
+			}
+		}
+	})
+}
+
+// BenchmarkIncrementMany measures incrementing 100 counters in a single batch, concurrently.
+func BenchmarkIncrementMany(b *testing.B) {
+	store := New()
+	deltas := make(map[string]int64, 100)
+	for i := 0; i < 100; i++ {
+		deltas[fmt.Sprintf("counter%d", i)] = 1
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			store.IncrementMany(deltas, 0)
+		}
+	})
+}
+
+func TestCounter(t *testing.T) {
+	store := New()
+	counter := store.Counter("hits", time.Minute)
+
+	// Test concurrent increments through several handles converging to the right total.
+	const numGoroutines, numIncrements = 10, 100
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter := store.Counter("hits", time.Minute)
+			for j := 0; j < numIncrements; j++ {
+				counter.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	if value := counter.Get(); value != numGoroutines*numIncrements {
+		t.Errorf("Expected %d, but got %d", numGoroutines*numIncrements, value)
+	}
+
+	if value := counter.Add(-500); value != 500 {
+		t.Errorf("Expected 500, but got %d", value)
+	}
+
+	// Test Reset starting the counter again from 0.
+	counter.Reset()
+	if value := counter.Get(); value != 0 {
+		t.Errorf("Expected 0 after Reset, but got %d", value)
+	}
+	if value := counter.Inc(); value != 1 {
+		t.Errorf("Expected 1, but got %d", value)
+	}
+
+	// Test a key holding a non-integer reading as 0.
+	store.Set("name", "remo", 0)
+	if value := store.Counter("name", 0).Inc(); value != 0 {
+		t.Errorf("Expected 0 for a non-integer, but got %d", value)
+	}
+}
+
+func TestStorage_DecrAndDeleteIfZero(t *testing.T) {
+	store := New()
+	store.Set("refs", 2, time.Minute)
+
+	// Test a decrement above zero keeping the key and its expiration.
+	expiration := store.data["refs"].expiration
+	if value, deleted, err := store.DecrAndDeleteIfZero("refs"); err != nil || value != 1 || deleted {
+		t.Errorf("Expected 1 without deletion, got %d, %v, %v", value, deleted, err)
+	}
+	if !store.data["refs"].expiration.Equal(expiration) {
+		t.Errorf("Expected the expiration to be kept")
+	}
+
+	// Test counting down to zero deleting the key.
+	if value, deleted, err := store.DecrAndDeleteIfZero("refs"); err != nil || value != 0 || !deleted {
+		t.Errorf("Expected 0 with deletion, got %d, %v, %v", value, deleted, err)
+	}
+	if _, err := store.Get("refs"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after deletion, but got %v", err)
+	}
+
+	// Test missing keys and values that are not integers.
+	if _, _, err := store.DecrAndDeleteIfZero("refs"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	store.Set("stringKey", "value", 0)
+	if _, _, err := store.DecrAndDeleteIfZero("stringKey"); !errors.Is(err, ErrNotAnInteger) {
+		t.Errorf("Expected ErrNotAnInteger, but got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"time"
+)
+
+// SetWithDependency sets a key-value pair like Set and makes it depend on the keys in
+// dependsOn: whenever one of them is set, deleted or expires, key is deleted too, cascading
+// to the keys depending on it in turn. Cascaded deletes run outside the lock via a background
+// goroutine, so they are applied shortly after the change that triggers them. Setting key
+// again, with or without dependencies, replaces its previous dependencies.
+// It returns ErrDependencyCycle if a key in dependsOn depends on key, directly or not.
+func (s *Storage) SetWithDependency(key string, value interface{}, ttl time.Duration, dependsOn ...string) (err error) {
+	defer wrapOpError("SetWithDependency", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	item, err := s.prepareItem(key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if err := s.makeRoom(context.Background(), key); err != nil {
+		return err
+	}
+	if err := s.checkWritable(key); err != nil {
+		return err
+	}
+	for _, dependency := range dependsOn {
+		if s.dependsOn(dependency, key) {
+			return ErrDependencyCycle
+		}
+	}
+
+	s.storeItem(key, item)
+	for _, dependency := range dependsOn {
+		s.linkDependency(key, dependency)
+	}
+	return nil
+}
+
+// dependsOn reports whether key depends on target, directly or not.
+// The caller must hold the lock.
+func (s *Storage) dependsOn(key, target string) bool {
+	visited := make(map[string]struct{})
+	pending := []string{key}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if current == target {
+			return true
+		}
+		if _, seen := visited[current]; seen {
+			continue
+		}
+		visited[current] = struct{}{}
+		pending = append(pending, s.dependencies[current]...)
+	}
+	return false
+}
+
+// linkDependency records that key depends on dependency. The caller must hold the write lock.
+func (s *Storage) linkDependency(key, dependency string) {
+	s.dependencies[key] = append(s.dependencies[key], dependency)
+	if s.dependents[dependency] == nil {
+		s.dependents[dependency] = make(map[string]struct{})
+	}
+	s.dependents[dependency][key] = struct{}{}
+}
+
+// unlinkDependencies forgets the dependencies of key. The caller must hold the write lock.
+func (s *Storage) unlinkDependencies(key string) {
+	for _, dependency := range s.dependencies[key] {
+		delete(s.dependents[dependency], key)
+		if len(s.dependents[dependency]) == 0 {
+			delete(s.dependents, dependency)
+		}
+	}
+	delete(s.dependencies, key)
+}
+
+// invalidateDependents schedules the deletion of the keys depending on key, outside the lock.
+// The caller must hold the write lock.
+func (s *Storage) invalidateDependents(key string) {
+	dependents := s.dependents[key]
+	if len(dependents) == 0 {
+		return
+	}
+
+	// Capture the current items so a dependent set again in the meantime is kept.
+	items := make(map[string]*item, len(dependents))
+	for dependent := range dependents {
+		if item, exists := s.data[dependent]; exists {
+			items[dependent] = item
+		}
+	}
+	s.safeGo(func() {
+		s.removeItems(items)
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor fails the test if condition does not become true within a second.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met within a second")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// isMissing reports whether key has no live value in store.
+func isMissing(store *Storage, key string) bool {
+	_, err := store.Get(key)
+	return err != nil
+}
+
+func TestStorage_SetWithDependency(t *testing.T) {
+	store := New()
+
+	store.Set("user", "alice", 0)
+	err := store.SetWithDependency("profile", "alice's profile", 0, "user")
+	if err != nil {
+		t.Fatalf("SetWithDependency() failed: %v", err)
+	}
+	if _, err := store.Get("profile"); err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+
+	// Test a Set of the dependency invalidating the dependent.
+	store.Set("user", "bob", 0)
+	waitFor(t, func() bool { return isMissing(store, "profile") })
+
+	// Test a dependent set again after the change being kept.
+	store.SetWithDependency("profile", "bob's profile", 0, "user")
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Get("profile"); err != nil {
+		t.Errorf("Expected the new profile to be kept, but got %v", err)
+	}
+
+	// Test a Delete of the dependency invalidating the dependent.
+	store.Delete("user")
+	waitFor(t, func() bool { return isMissing(store, "profile") })
+
+	// Test the expiry of the dependency invalidating the dependent.
+	store.Set("session", "token", 50*time.Millisecond)
+	store.SetWithDependency("view", "rendered", 0, "session")
+	time.Sleep(100 * time.Millisecond)
+	store.PurgeExpired()
+	waitFor(t, func() bool { return isMissing(store, "view") })
+
+	// Test a plain Set replacing the dependencies of a key.
+	store.Set("user", "carol", 0)
+	store.SetWithDependency("profile", "carol's profile", 0, "user")
+	store.Set("profile", "standalone", 0)
+	store.Set("user", "dave", 0)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Get("profile"); err != nil {
+		t.Errorf("Expected the standalone profile to be kept, but got %v", err)
+	}
+}
+
+func TestStorage_SetWithDependencyChain(t *testing.T) {
+	store := New()
+
+	store.Set("a", 1, 0)
+	store.SetWithDependency("b", 2, 0, "a")
+	store.SetWithDependency("c", 3, 0, "b")
+	store.SetWithDependency("d", 4, 0, "b", "a")
+
+	store.Delete("a")
+	waitFor(t, func() bool {
+		return isMissing(store, "b") && isMissing(store, "c") && isMissing(store, "d")
+	})
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	if len(store.dependencies) != 0 || len(store.dependents) != 0 {
+		t.Errorf("Expected the dependency graph to be empty, but got %v and %v", store.dependencies, store.dependents)
+	}
+}
+
+func TestStorage_SetWithDependencyCycle(t *testing.T) {
+	store := New()
+
+	// Test a key depending on itself.
+	if err := store.SetWithDependency("a", 1, 0, "a"); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected ErrDependencyCycle, but got %v", err)
+	}
+
+	// Test an indirect cycle.
+	store.SetWithDependency("a", 1, 0, "b")
+	store.SetWithDependency("b", 2, 0, "c")
+	if err := store.SetWithDependency("c", 3, 0, "a"); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected ErrDependencyCycle, but got %v", err)
+	}
+	if _, err := store.Get("c"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected the rejected key not to be stored, but got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "time"
+
+// eventBufferSize is the capacity of the channels returned by Events and EventsFiltered.
+const eventBufferSize = 128
+
+// EventType is the kind of change an Event reports.
+type EventType int
+
+const (
+	// EventSet reports that a value was stored under a key.
+	EventSet EventType = iota
+	// EventDelete reports that a key was removed, other than by expiring or being evicted.
+	EventDelete
+	// EventExpire reports that an expired key was removed.
+	EventExpire
+	// EventEvict reports that a live key was removed to make room under PolicyEvict.
+	EventEvict
+)
+
+// Event is a change to a key of storage.
+type Event struct {
+	Type EventType
+	Key  string
+}
+
+// subscriber is a channel returned by EventsFiltered and the event types it receives.
+type subscriber struct {
+	events chan Event
+	types  map[EventType]struct{} // nil receives every type
+}
+
+// Events returns a channel receiving an event for every change to a key of storage, and a
+// function that unsubscribes it. It is the same as EventsFiltered with no types.
+func (s *Storage) Events() (<-chan Event, func()) {
+	return s.EventsFiltered()
+}
+
+// EventsFiltered returns a channel receiving events of the given types, or of every type if
+// none is given. Events are filtered when they are published, so events of other types never
+// reach the channel. Each call returns its own channel, which receives its own copy of every
+// matching event, so several filtered subscribers do not compete for events.
+//
+// Events are sent without blocking while the storage is locked: the channel is buffered,
+// and events arriving while its buffer is full are dropped, so it must be drained promptly.
+//
+// The returned function unsubscribes the channel and closes it, after which events already
+// buffered can still be received. It may be called more than once. Close closes the
+// channels of every subscriber still subscribed, once the events of pending work are
+// delivered; a channel subscribed after Close is returned closed.
+func (s *Storage) EventsFiltered(types ...EventType) (<-chan Event, func()) {
+	sub := &subscriber{events: make(chan Event, eventBufferSize)}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]struct{}, len(types))
+		for _, typ := range types {
+			sub.types[typ] = struct{}{}
+		}
+	}
+
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	if s.subscribersClosed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	s.subscribers = append(s.subscribers, sub)
+	return sub.events, func() { s.unsubscribe(sub) }
+}
+
+// unsubscribe removes sub and closes its channel, unless it was already removed.
+func (s *Storage) unsubscribe(sub *subscriber) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for i, subscribed := range s.subscribers {
+		if subscribed == sub {
+			s.subscribers = append(s.subscribers[:i:i], s.subscribers[i+1:]...)
+			close(sub.events)
+			return
+		}
+	}
+}
+
+// closeSubscribers closes the channel of every subscriber and makes later subscriptions
+// return closed channels.
+func (s *Storage) closeSubscribers() {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for _, sub := range s.subscribers {
+		close(sub.events)
+	}
+	s.subscribers = nil
+	s.subscribersClosed = true
+}
+
+// DrainEvents returns the events buffered with WithPolledEvents since the previous call,
+// oldest first, and empties the buffer. It returns nil if there are none or storage does
+// not poll events.
+func (s *Storage) DrainEvents() []Event {
+	s.polledEventsMu.Lock()
+	defer s.polledEventsMu.Unlock()
+	events := s.polledEvents
+	s.polledEvents = nil
+	return events
+}
+
+// publish sends an event of type typ for key to the subscribers that want it, or buffers
+// it with WithEventCoalescing.
+func (s *Storage) publish(typ EventType, key string) {
+	event := Event{Type: typ, Key: key}
+	if s.eventCoalesceEvery > 0 && s.bufferEvent(event) {
+		return
+	}
+	s.deliver(event)
+}
+
+// deliver sends event to the subscribers that want it, dropping it for those whose buffer
+// is full, or buffers it for DrainEvents with WithPolledEvents.
+func (s *Storage) deliver(event Event) {
+	if s.polledEventsMax > 0 {
+		s.polledEventsMu.Lock()
+		if len(s.polledEvents) < s.polledEventsMax {
+			s.polledEvents = append(s.polledEvents, event)
+		}
+		s.polledEventsMu.Unlock()
+		return
+	}
+
+	s.eventsMu.RLock()
+	defer s.eventsMu.RUnlock()
+	for _, sub := range s.subscribers {
+		if sub.types != nil {
+			if _, wanted := sub.types[event.Type]; !wanted {
+				continue
+			}
+		}
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+// bufferEvent buffers event for the event coalescing goroutine, unless the same event is
+// already buffered. It reports false if storage was closed, in which case the caller must
+// deliver the event itself.
+func (s *Storage) bufferEvent(event Event) bool {
+	s.pendingEventsMu.Lock()
+	defer s.pendingEventsMu.Unlock()
+	if s.eventsClosed {
+		return false
+	}
+	if _, buffered := s.bufferedEvents[event]; !buffered {
+		s.bufferedEvents[event] = struct{}{}
+		s.pendingEvents = append(s.pendingEvents, event)
+	}
+	return true
+}
+
+// startEventCoalescing starts the goroutine delivering buffered events every interval.
+func (s *Storage) startEventCoalescing(interval time.Duration) {
+	s.bufferedEvents = make(map[Event]struct{})
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.eventsStop, s.eventsDone = stop, done
+	s.safeGo(func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.flushEvents()
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// stopEventCoalescing stops the event coalescing goroutine and delivers the events still
+// buffered. Later events are delivered right away.
+func (s *Storage) stopEventCoalescing() {
+	if s.eventCoalesceEvery <= 0 {
+		return
+	}
+
+	s.pendingEventsMu.Lock()
+	if s.eventsClosed {
+		s.pendingEventsMu.Unlock()
+		return
+	}
+	s.eventsClosed = true
+	s.pendingEventsMu.Unlock()
+
+	close(s.eventsStop)
+	<-s.eventsDone
+	s.flushEvents()
+}
+
+// flushEvents delivers the buffered events in the order they were first buffered.
+func (s *Storage) flushEvents() {
+	s.pendingEventsMu.Lock()
+	pending := s.pendingEvents
+	s.pendingEvents = nil
+	s.bufferedEvents = make(map[Event]struct{})
+	s.pendingEventsMu.Unlock()
+
+	for _, event := range pending {
+		s.deliver(event)
+	}
+}
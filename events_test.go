@@ -0,0 +1,170 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStorage_EventsFiltered(t *testing.T) {
+	store := New()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	all, _ := store.Events()
+	expirations, _ := store.EventsFiltered(EventExpire)
+
+	store.Set("key", "value", 0)
+	store.Set("expiringKey", "value", time.Second)
+	store.Delete("key")
+	now = now.Add(2 * time.Second)
+	store.PurgeExpired()
+
+	// Test the filtered subscriber receiving only the expiration.
+	select {
+	case event := <-expirations:
+		if event != (Event{Type: EventExpire, Key: "expiringKey"}) {
+			t.Errorf("Expected the expiration of expiringKey, but got %+v", event)
+		}
+	default:
+		t.Fatalf("Expected an expire event")
+	}
+	select {
+	case event := <-expirations:
+		t.Errorf("Expected no other events, but got %+v", event)
+	default:
+	}
+
+	// Test the unfiltered subscriber receiving every event in order.
+	expected := []Event{
+		{Type: EventSet, Key: "key"},
+		{Type: EventSet, Key: "expiringKey"},
+		{Type: EventDelete, Key: "key"},
+		{Type: EventExpire, Key: "expiringKey"},
+	}
+	for _, want := range expected {
+		select {
+		case event := <-all:
+			if event != want {
+				t.Errorf("Expected %+v, but got %+v", want, event)
+			}
+		default:
+			t.Fatalf("Expected %+v, but got no event", want)
+		}
+	}
+}
+
+func TestStorage_EventCoalescing(t *testing.T) {
+	store := New(WithEventCoalescing(10 * time.Millisecond))
+	events, _ := store.Events()
+
+	// Hammer a single key, then delete another one.
+	const sets = 10000
+	for i := 0; i <= sets; i++ {
+		store.Set("progress", i, 0)
+	}
+	store.Set("key", "value", 0)
+	store.Delete("key")
+	store.Close()
+
+	// Test subscribers receiving far fewer events than Sets, merged per key and type.
+	received := make(map[Event]int)
+	total := 0
+	for len(events) > 0 {
+		received[<-events]++
+		total++
+	}
+	if total == 0 || total > sets/20 {
+		t.Errorf("Expected a few events, but got %d", total)
+	}
+	if received[Event{Type: EventSet, Key: "key"}] != 1 || received[Event{Type: EventDelete, Key: "key"}] != 1 {
+		t.Errorf("Expected one set and one delete event for key, but got %v", received)
+	}
+
+	// Test storage ending in the final state.
+	if item, exists := store.data["progress"]; !exists || item.value != sets {
+		t.Errorf("Expected %d, got %v", sets, item)
+	}
+}
+
+// Test that DrainEvents returns the buffered events once, and drops events past the buffer size.
+func TestStorage_DrainEvents(t *testing.T) {
+	store := New(WithPolledEvents(3))
+	subscriber, _ := store.Events()
+
+	store.Set("key", "value", 0)
+	store.Set("otherKey", "value", 0)
+	store.Delete("key")
+
+	expected := []Event{
+		{Type: EventSet, Key: "key"},
+		{Type: EventSet, Key: "otherKey"},
+		{Type: EventDelete, Key: "key"},
+	}
+	if events := store.DrainEvents(); !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, but got %v", expected, events)
+	}
+	if events := store.DrainEvents(); len(events) != 0 {
+		t.Errorf("Expected an empty buffer after draining, but got %v", events)
+	}
+	select {
+	case event := <-subscriber:
+		t.Errorf("Expected no pushed events, but got %+v", event)
+	default:
+	}
+
+	// Test events past the buffer size being dropped until the next drain.
+	for i := 0; i < 5; i++ {
+		store.Set("key", i, 0)
+	}
+	if events := store.DrainEvents(); len(events) != 3 {
+		t.Errorf("Expected 3 events, but got %v", events)
+	}
+}
+
+// Test that subscribers can unsubscribe and that Close closes the channels still subscribed.
+func TestStorage_EventsUnsubscribe(t *testing.T) {
+	store := New()
+	events, cancel := store.Events()
+	kept, _ := store.EventsFiltered(EventSet)
+
+	store.Set("key", "value", 0)
+	cancel()
+	cancel()
+	store.Set("otherKey", "value", 0)
+
+	// Test the buffered event still being received before the channel reports closed.
+	if event, ok := <-events; !ok || event.Key != "key" {
+		t.Errorf("Expected the buffered event for key, but got %v, %v", event, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("Expected the unsubscribed channel to be closed")
+	}
+	store.eventsMu.RLock()
+	if len(store.subscribers) != 1 {
+		t.Errorf("Expected 1 subscriber left, but got %d", len(store.subscribers))
+	}
+	store.eventsMu.RUnlock()
+
+	// Test Close closing the remaining channel and later subscriptions.
+	store.Close()
+	for range kept {
+	}
+	late, _ := store.Events()
+	if _, ok := <-late; ok {
+		t.Errorf("Expected a channel subscribed after Close to be closed")
+	}
+}
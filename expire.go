@@ -0,0 +1,52 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "sync/atomic"
+
+// KeyValue is a key and the value stored under it.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// expireItem removes the expired item stored under key and, if an expire callback is set,
+// appends it to expired for notifyExpired. The caller must hold the write lock.
+func (s *Storage) expireItem(key string, item *item, expired []KeyValue) []KeyValue {
+	s.removeItemAs(key, EventExpire)
+	atomic.AddUint64(&s.expirations, 1)
+	if s.onExpire != nil {
+		expired = append(expired, KeyValue{Key: key, Value: item.value})
+	}
+	return expired
+}
+
+// notifyExpired passes entries collected by expireItem to the expire callback in the
+// background, so the callback never runs under the lock.
+func (s *Storage) notifyExpired(expired []KeyValue) {
+	if len(expired) == 0 {
+		return
+	}
+	s.safeGo(func() {
+		for i := range expired {
+			value, err := s.decode(expired[i].Value)
+			if err != nil {
+				s.logger.Printf("Remo: [Expire] decoding key %q: %v", expired[i].Key, err)
+			}
+			expired[i].Value = value
+		}
+		s.onExpire(expired)
+	})
+}
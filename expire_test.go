@@ -0,0 +1,75 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStorage_BatchExpireCallback(t *testing.T) {
+	batches := make(chan []KeyValue, 10)
+	store := New(WithBatchExpireCallback(func(expired []KeyValue) {
+		batches <- expired
+	}))
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	const numKeys = 1000
+	for i := 0; i < numKeys; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, time.Second)
+	}
+	store.Set("persistentKey", "value", 0)
+
+	// Test a pass expiring many keys reporting them in a single call.
+	now = now.Add(2 * time.Second)
+	store.runCleanupPass()
+
+	var expired []KeyValue
+	select {
+	case expired = <-batches:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the callback to be called")
+	}
+	if len(expired) != numKeys {
+		t.Fatalf("Expected %d expired entries, but got %d", numKeys, len(expired))
+	}
+	seen := make(map[string]bool, numKeys)
+	for _, kv := range expired {
+		var i int
+		fmt.Sscanf(kv.Key, "key%d", &i)
+		if kv.Value != i {
+			t.Errorf("Expected %d for %s, but got %v", i, kv.Key, kv.Value)
+		}
+		seen[kv.Key] = true
+	}
+	if len(seen) != numKeys {
+		t.Errorf("Expected %d distinct keys, but got %d", numKeys, len(seen))
+	}
+	select {
+	case batch := <-batches:
+		t.Errorf("Expected a single call, but got another with %d entries", len(batch))
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Test deletes not being reported.
+	store.Delete("persistentKey")
+	select {
+	case batch := <-batches:
+		t.Errorf("Expected no call for a delete, but got %v", batch)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+// matchGlob reports whether key matches pattern, in which '*' matches any run of bytes,
+// including an empty one, and '?' matches any single byte. Every other byte matches
+// itself; there are no character classes or escapes.
+func matchGlob(pattern, key string) bool {
+	// Backtrack to just after the last '*' on a mismatch, letting it absorb one more byte.
+	p, k := 0, 0
+	star, starKey := -1, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, starKey = p, k
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case star >= 0:
+			starKey++
+			p, k = star+1, starKey
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		expected     bool
+	}{
+		{"session:*", "session:abc", true},
+		{"session:*", "session:", true},
+		{"session:*", "sessions:abc", false},
+		{"*:user/*", "tenant:user/42", true},
+		{"*", "", true},
+		{"", "", true},
+		{"", "key", false},
+		{"k?y", "key", true},
+		{"k?y", "ky", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"exact", "exact", true},
+	}
+	for _, test := range tests {
+		if got := matchGlob(test.pattern, test.key); got != test.expected {
+			t.Errorf("matchGlob(%q, %q): expected %v, but got %v", test.pattern, test.key, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+// RenameMerge merges the hash stored under src into the one stored under dst and deletes
+// src, atomically. A hash is a value of type map[string]interface{}. Fields present in both
+// keep dst's value unless overwriteFields is set. dst keeps its expiration; if dst is
+// missing or expired, src is renamed to dst with src's expiration. It returns
+// ErrWrongType if either key holds a value that is not a hash, and ErrKeyNotFound or
+// ErrKeyExpired if src has no live value.
+func (s *Storage) RenameMerge(src, dst string, overwriteFields bool) (err error) {
+	defer wrapOpError("RenameMerge", src, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	now := s.now()
+	srcItem, exists := s.data[src]
+	if !exists {
+		return keyNotFound(src)
+	}
+	if srcItem.isExpiredAt(now) {
+		return keyExpired(src)
+	}
+	if err := s.checkWritable(src); err != nil {
+		return err
+	}
+	if err := s.checkWritable(dst); err != nil {
+		return err
+	}
+	srcHash, err := s.hash(srcItem)
+	if err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+
+	// Build a new map rather than merging in place, so hashes returned earlier never change.
+	merged := make(map[string]interface{}, len(srcHash))
+	expiration := srcItem.expiration
+	if dstItem, exists := s.data[dst]; exists && !dstItem.isExpiredAt(now) {
+		dstHash, err := s.hash(dstItem)
+		if err != nil {
+			return err
+		}
+		for field, value := range dstHash {
+			merged[field] = value
+		}
+		expiration = dstItem.expiration
+	}
+	for field, value := range srcHash {
+		if _, conflict := merged[field]; conflict && !overwriteFields {
+			continue
+		}
+		merged[field] = value
+	}
+
+	stored, err := s.encode(merged)
+	if err != nil {
+		return err
+	}
+	if err := s.checkValueSize(stored); err != nil {
+		return err
+	}
+	s.storeItem(dst, newItem(stored, expiration))
+	s.dropPendingWrite(src)
+	s.removeItem(src)
+	return nil
+}
+
+// hash returns the hash held by item, or ErrWrongType if it holds another kind of value.
+func (s *Storage) hash(item *item) (map[string]interface{}, error) {
+	value, err := s.decode(item.value)
+	if err != nil {
+		return nil, err
+	}
+	hash, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return hash, nil
+}
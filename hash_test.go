@@ -0,0 +1,93 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStorage_RenameMerge(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		overwriteFields bool
+		expected        map[string]interface{}
+	}{
+		{"KeepDst", false, map[string]interface{}{"a": 1, "b": 2, "c": 30}},
+		{"OverwriteDst", true, map[string]interface{}{"a": 1, "b": 20, "c": 30}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := New()
+			store.Set("src", map[string]interface{}{"a": 1, "b": 20}, time.Minute)
+			store.Set("dst", map[string]interface{}{"b": 2, "c": 30}, time.Hour)
+			expiration := store.data["dst"].expiration
+
+			if err := store.RenameMerge("src", "dst", tt.overwriteFields); err != nil {
+				t.Fatalf("RenameMerge() failed: %v", err)
+			}
+			value, err := store.Get("dst")
+			if err != nil || !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %v, got %v, %v", tt.expected, value, err)
+			}
+			if _, err := store.Get("src"); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Expected src to be deleted, but got %v", err)
+			}
+
+			// Test dst keeping its TTL.
+			if !store.data["dst"].expiration.Equal(expiration) {
+				t.Errorf("Expected dst to keep its expiration")
+			}
+		})
+	}
+}
+
+func TestStorage_RenameMergeMissingDst(t *testing.T) {
+	store := New()
+	src := map[string]interface{}{"a": 1}
+	store.Set("src", src, time.Minute)
+	expiration := store.data["src"].expiration
+
+	// Test a missing dst receiving src's fields and expiration.
+	if err := store.RenameMerge("src", "dst", false); err != nil {
+		t.Fatalf("RenameMerge() failed: %v", err)
+	}
+	if value, _ := store.Get("dst"); !reflect.DeepEqual(value, src) {
+		t.Errorf("Expected %v, but got %v", src, value)
+	}
+	if !store.data["dst"].expiration.Equal(expiration) {
+		t.Errorf("Expected dst to take src's expiration")
+	}
+}
+
+func TestStorage_RenameMergeErrors(t *testing.T) {
+	store := New()
+	store.Set("hash", map[string]interface{}{"a": 1}, 0)
+	store.Set("string", "value", 0)
+
+	if err := store.RenameMerge("missing", "hash", false); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	if err := store.RenameMerge("string", "hash", false); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType for src, but got %v", err)
+	}
+	if err := store.RenameMerge("hash", "string", false); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType for dst, but got %v", err)
+	}
+	if _, err := store.Get("hash"); err != nil {
+		t.Errorf("Expected src to be kept after an error, but got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// idleCheckDivisor is how many times per idle timeout the idle watcher checks for activity.
+const idleCheckDivisor = 4
+
+// markActive records an operation on storage for the idle watcher.
+func (s *Storage) markActive() {
+	if s.idleTimeout > 0 {
+		atomic.StoreInt64(&s.lastActive, s.now().UnixNano())
+	}
+}
+
+// startIdleWatch starts the goroutine resetting storage once it has been idle for the idle
+// timeout.
+func (s *Storage) startIdleWatch() {
+	s.markActive()
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.idleStop, s.idleDone = stop, done
+	s.safeGo(func() {
+		defer close(done)
+		ticker := time.NewTicker(s.idleTimeout / idleCheckDivisor)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.checkIdle() && s.closeOnIdle {
+					// Close would wait for this goroutine, so stop the rest directly.
+					if atomic.CompareAndSwapInt32(&s.closing, 0, 1) {
+						s.finishClose()
+					}
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// checkIdle resets storage if no operation happened within the idle timeout, and reports
+// whether it did.
+func (s *Storage) checkIdle() bool {
+	now := s.now()
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastActive))) < s.idleTimeout {
+		return false
+	}
+	s.logger.Printf("Remo: [Idle] no operation for %v; resetting storage", s.idleTimeout)
+	s.Reset()
+	atomic.StoreInt64(&s.lastActive, now.UnixNano())
+	return true
+}
+
+// stopIdleWatch stops the idle watcher, if it is running, and waits for it to exit.
+func (s *Storage) stopIdleWatch() {
+	if s.idleStop == nil {
+		return
+	}
+	s.idleStopOnce.Do(func() {
+		close(s.idleStop)
+	})
+	<-s.idleDone
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorage_IdleStoreTimeout(t *testing.T) {
+	store := New(WithIdleStoreTimeout(time.Hour, false), WithLogger(&recordingLogger{}))
+	defer store.Close()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// Test operations restarting the timer.
+	store.Set("key", "value", 0)
+	now = now.Add(40 * time.Minute)
+	store.Get("key")
+	now = now.Add(40 * time.Minute)
+	if store.checkIdle() {
+		t.Errorf("Expected no reset within the idle window of the last operation")
+	}
+	if _, err := store.Get("key"); err != nil {
+		t.Errorf("Expected the key to survive, but got %v", err)
+	}
+
+	// Test storage being cleared after the idle window with no activity.
+	now = now.Add(time.Hour)
+	if !store.checkIdle() {
+		t.Errorf("Expected a reset after the idle window")
+	}
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after the reset, but got %v", err)
+	}
+}
+
+// Test that operations other than Get, Set and Delete keep storage alive too.
+func TestStorage_IdleStoreTimeoutIncrement(t *testing.T) {
+	store := New(WithIdleStoreTimeout(time.Hour, false), WithLogger(&recordingLogger{}))
+	defer store.Close()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if _, err := store.Increment("counter", 1, 0); err != nil {
+			t.Fatalf("Increment() failed: %v", err)
+		}
+		now = now.Add(40 * time.Minute)
+		if store.checkIdle() {
+			t.Fatalf("Expected Increment to keep storage alive")
+		}
+	}
+	if store.ApproxLen() != 1 {
+		t.Errorf("Expected the counter to survive, but got %d entries", store.ApproxLen())
+	}
+}
+
+func TestStorage_IdleStoreTimeoutClose(t *testing.T) {
+	store := New(WithIdleStoreTimeout(20*time.Millisecond, true), WithAutoCleanup(time.Hour), WithLogger(&recordingLogger{}))
+	store.Set("key", "value", 0)
+
+	// Test the watcher resetting and closing idle storage.
+	waitFor(t, func() bool {
+		return store.ApproxLen() == 0 && !store.isCleanupRunning()
+	})
+	store.Close()
+}
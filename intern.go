@@ -0,0 +1,58 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "strings"
+
+// WithKeyInterning makes storage keep its own compact copy of each key and reuse it for
+// every later write to the same key. Without it, storage holds on to the string passed to
+// the most recent write, and with it whatever backs that string: a key sliced out of a
+// large request body or buffer keeps the whole buffer alive. With interning, callers'
+// strings are released as soon as the write returns, which pays off most when the same
+// keys are set repeatedly from freshly built or sliced strings.
+//
+// The intern table costs a map entry per live key and a lookup on every write; entries
+// are dropped when their key is removed.
+func WithKeyInterning() Option {
+	return func(s *Storage) {
+		s.interned = make(map[string]string)
+	}
+}
+
+// intern returns the interned copy of key, adding one if needed. It returns key unchanged
+// when interning is disabled. The caller must hold the write lock.
+func (s *Storage) intern(key string) string {
+	if s.interned == nil {
+		return key
+	}
+	if interned, ok := s.interned[key]; ok {
+		return interned
+	}
+	key = strings.Clone(key)
+	s.interned[key] = key
+	return key
+}
+
+// resetInterned replaces the intern table with the keys of data. The caller must hold
+// the write lock.
+func (s *Storage) resetInterned(data map[string]*item) {
+	if s.interned == nil {
+		return
+	}
+	s.interned = make(map[string]string, len(data))
+	for key := range data {
+		s.interned[key] = key
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// storedKey returns the key string held by the storage map for key.
+func storedKey(store *Storage, key string) string {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	for k := range store.data {
+		if k == key {
+			return k
+		}
+	}
+	return ""
+}
+
+func TestStorage_KeyInterning(t *testing.T) {
+	store := New(WithKeyInterning())
+
+	// Test the stored key not sharing memory with a key sliced out of a larger buffer.
+	buf := strings.Repeat("x", 1024) + "service/region/tenant"
+	key := buf[1024:]
+	if err := store.Set(key, "value1", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	first := storedKey(store, key)
+	if unsafe.StringData(first) == unsafe.StringData(key) {
+		t.Errorf("Expected the stored key to be a copy of the caller's key")
+	}
+
+	// Test later writes reusing the interned key.
+	if err := store.Set(strings.Clone(key), "value2", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if unsafe.StringData(storedKey(store, key)) != unsafe.StringData(first) {
+		t.Errorf("Expected the interned key to be reused")
+	}
+	if value, err := store.Get(key); err != nil || value != "value2" {
+		t.Errorf("Expected value2, got %v, %v", value, err)
+	}
+
+	// Test removed keys leaving the intern table.
+	store.Delete(key)
+	if len(store.interned) != 0 {
+		t.Errorf("Expected an empty intern table, but got %d entries", len(store.interned))
+	}
+}
+
+// BenchmarkKeyInterning reports the heap retained per key when keys are sliced out of
+// large buffers, with and without interning.
+func BenchmarkKeyInterning(b *testing.B) {
+	const numKeys = 100
+	for _, bm := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Plain", nil},
+		{"Interned", []Option{WithKeyInterning()}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				store := New(bm.opts...)
+				for j := 0; j < numKeys; j++ {
+					buf := strings.Repeat("x", 4096) + "service/region/tenant/" + strconv.Itoa(j)
+					store.Set(buf[4096:], j, 0)
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				if after.HeapAlloc > before.HeapAlloc {
+					retained += after.HeapAlloc - before.HeapAlloc
+				}
+				runtime.KeepAlive(store)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N*numKeys), "retained-B/key")
+		})
+	}
+}
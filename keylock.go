@@ -0,0 +1,59 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "sync"
+
+// keyLock is a per-key mutex, shared by the callers holding or waiting for it.
+type keyLock struct {
+	mu   sync.Mutex
+	refs int // guarded by Storage.keyLocksMu
+}
+
+// LockKey acquires a lock on key and returns the function releasing it, so callers building
+// their own read-through logic can make sure only one of them computes a key's value at a
+// time. The lock only serializes LockKey callers and GetOrCompute loads of the same key:
+// it is independent from the storage's data lock, so it does not block other keys, nor
+// reads and writes of key itself.
+//
+// The lock is not reentrant. To avoid deadlocks, always call unlock, typically with defer,
+// never lock a key already held by the same goroutine, and lock several keys in a
+// consistent order. Calling unlock more than once has no effect.
+func (s *Storage) LockKey(key string) (unlock func()) {
+	s.keyLocksMu.Lock()
+	if s.keyLocks == nil {
+		s.keyLocks = make(map[string]*keyLock)
+	}
+	lock, exists := s.keyLocks[key]
+	if !exists {
+		lock = &keyLock{}
+		s.keyLocks[key] = lock
+	}
+	lock.refs++
+	s.keyLocksMu.Unlock()
+
+	lock.mu.Lock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			lock.mu.Unlock()
+			s.keyLocksMu.Lock()
+			if lock.refs--; lock.refs == 0 {
+				delete(s.keyLocks, key)
+			}
+			s.keyLocksMu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStorage_LockKey(t *testing.T) {
+	store := New()
+	unlock := store.LockKey("a")
+
+	// Test a second goroutine waiting for the lock on the same key.
+	acquired := make(chan struct{})
+	go func() {
+		unlock := store.LockKey("a")
+		defer unlock()
+		close(acquired)
+	}()
+
+	// Test a third goroutine proceeding on another key meanwhile.
+	other := make(chan struct{})
+	go func() {
+		unlock := store.LockKey("b")
+		defer unlock()
+		close(other)
+	}()
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the lock on another key to be acquired")
+	}
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second goroutine to wait for the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	unlock() // Unlocking again has no effect.
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the second goroutine to acquire the lock once released")
+	}
+
+	// Test released locks being dropped.
+	waitFor(t, func() bool {
+		store.keyLocksMu.Lock()
+		defer store.keyLocksMu.Unlock()
+		return len(store.keyLocks) == 0
+	})
+}
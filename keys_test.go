@@ -0,0 +1,160 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// binaryKeys are keys made of raw bytes, including NULs, control characters and bytes
+// that are not valid UTF-8. Some differ only after a NUL.
+var binaryKeys = []string{
+	"\x00",
+	"\x00\x00",
+	"a\x00b",
+	"a\x00c",
+	"\x01\x02\x1b[0m\n\r\t",
+	"\xff\xfe\x80",
+	"\xc3\x28",
+	"prefix\x00",
+}
+
+func TestStorage_BinaryKeys(t *testing.T) {
+	store := New()
+
+	for i, key := range binaryKeys {
+		if err := store.Set(key, i, 0); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+	}
+
+	// Test every key-consuming read keeping the keys apart.
+	for i, key := range binaryKeys {
+		value, err := store.Get(key)
+		if err != nil || value != i {
+			t.Errorf("Get(%q): expected %d, got %v, %v", key, i, value, err)
+		}
+		if found, _ := store.Has(key); !found {
+			t.Errorf("Has(%q): expected true", key)
+		}
+	}
+	values, errs := store.GetSlice(binaryKeys)
+	for i := range binaryKeys {
+		if values[i] != i || errs[i] != nil {
+			t.Errorf("GetSlice: expected %d at %d, got %v, %v", i, i, values[i], errs[i])
+		}
+	}
+
+	// Test writes addressing the exact key.
+	if swapped, err := store.CompareAndSwap("a\x00b", 2, "swapped"); !swapped || err != nil {
+		t.Errorf("CompareAndSwap: expected a swap, got %v, %v", swapped, err)
+	}
+	if value, _ := store.Get("a\x00c"); value != 3 {
+		t.Errorf("Expected the neighbouring key to be untouched, but got %v", value)
+	}
+	if value, err := store.Increment("\x00\x00", 1, 0); value != 2 || err != nil {
+		t.Errorf("Increment: expected 2, got %v, %v", value, err)
+	}
+	if err := store.RingPush("\xff\xfe\x80", "event", 2, 0); !errors.Is(err, ErrWrongType) {
+		t.Errorf("RingPush: expected ErrWrongType for an int value, but got %v", err)
+	}
+
+	// Test snapshots and moves round-tripping the keys.
+	snap := store.Snapshot()
+	store.Reset()
+	store.Restore(snap)
+	dst := New()
+	for _, key := range binaryKeys {
+		if err := store.MoveTo(dst, key); err != nil {
+			t.Errorf("MoveTo(%q) failed: %v", key, err)
+		}
+	}
+	if len(dst.data) != len(binaryKeys) {
+		t.Errorf("Expected %d moved keys, but got %d", len(binaryKeys), len(dst.data))
+	}
+
+	// Test deletes only removing the exact key.
+	dst.Delete("a\x00b")
+	if _, err := dst.Get("a\x00"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for a prefix, but got %v", err)
+	}
+	if _, err := dst.Get("a\x00c"); err != nil {
+		t.Errorf("Expected a\\x00c to remain, but got %v", err)
+	}
+}
+
+func TestStorage_BinaryTenantKeys(t *testing.T) {
+	store := New(WithTenantFunc(func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantContextKey{}).(string)
+		return tenant
+	}))
+
+	// Test tenants and keys containing NULs and separators never colliding.
+	store.SetContext(withTenant("a\x00"), "b", 1, 0)
+	store.SetContext(withTenant("a"), "\x00b", 2, 0)
+	store.SetContext(withTenant("a:"), "b", 3, 0)
+	store.SetContext(withTenant("a"), ":b", 4, 0)
+
+	for i, pair := range [][2]string{{"a\x00", "b"}, {"a", "\x00b"}, {"a:", "b"}, {"a", ":b"}} {
+		value, err := store.GetContext(withTenant(pair[0]), pair[1])
+		if err != nil || value != i+1 {
+			t.Errorf("Expected %d for tenant %q and key %q, got %v, %v", i+1, pair[0], pair[1], value, err)
+		}
+	}
+}
+
+// Test that binary keys survive a round trip through SaveToFile and LoadFromFile.
+func TestStorage_BinaryKeysPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.remo")
+	store := New()
+	for _, key := range binaryKeys {
+		store.Set(key, key, 0)
+	}
+	if err := store.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() failed: %v", err)
+	}
+
+	loaded := New()
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() failed: %v", err)
+	}
+	if loaded.Len() != len(binaryKeys) {
+		t.Errorf("Expected %d keys, but got %d", len(binaryKeys), loaded.Len())
+	}
+	for _, key := range binaryKeys {
+		if value, err := loaded.Get(key); err != nil || value != key {
+			t.Errorf("Expected %q, got %v, %v", key, value, err)
+		}
+	}
+}
+
+func TestStorage_BinaryKeysSerialized(t *testing.T) {
+	store := New(WithSerializedValues(GobCodec{}))
+
+	for _, key := range binaryKeys {
+		if err := store.Set(key, key, 0); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+	}
+	for _, key := range binaryKeys {
+		value, err := store.Get(key)
+		if err != nil || value != key {
+			t.Errorf("Expected %q, got %q, %v", key, value, err)
+		}
+	}
+}
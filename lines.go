@@ -0,0 +1,57 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// LoadLines seeds storage from r, which holds one key=value or tab-separated key and value
+// per line, storing each value as a string with the given TTL, and returns the number of
+// entries stored. The key and value are split at the first '=' or tab, and surrounding
+// whitespace is trimmed from both. Blank lines and lines starting with '#' are skipped.
+// Loading stops at the first line without a separator or with an empty key, reported as
+// ErrMalformedLine, or that Set rejects; the error names the line number, and the entries
+// loaded before it are kept.
+func (s *Storage) LoadLines(r io.Reader, ttl time.Duration) (int, error) {
+	if err := s.checkOpen(); err != nil {
+		return 0, err
+	}
+	loaded := 0
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.IndexAny(line, "=\t")
+		if separator < 0 {
+			return loaded, fmt.Errorf("line %d: %w", number, ErrMalformedLine)
+		}
+		key := strings.TrimSpace(line[:separator])
+		if key == "" {
+			return loaded, fmt.Errorf("line %d: %w", number, ErrMalformedLine)
+		}
+		if err := s.Set(key, strings.TrimSpace(line[separator+1:]), ttl); err != nil {
+			return loaded, fmt.Errorf("line %d: %w", number, err)
+		}
+		loaded++
+	}
+	return loaded, scanner.Err()
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorage_LoadLines(t *testing.T) {
+	store := New()
+	input := "# seed data\n\nhost=localhost\nport\t8080\n  name = remo dev  \nurl=http://x/?a=b\n"
+
+	// Test valid lines being loaded and comments and blanks skipped.
+	loaded, err := store.LoadLines(strings.NewReader(input), time.Minute)
+	if err != nil || loaded != 4 {
+		t.Fatalf("Expected 4 entries, got %d, %v", loaded, err)
+	}
+	expected := map[string]string{"host": "localhost", "port": "8080", "name": "remo dev", "url": "http://x/?a=b"}
+	for key, want := range expected {
+		if value, err := store.Get(key); err != nil || value != want {
+			t.Errorf("Expected %q for %s, got %v, %v", want, key, value, err)
+		}
+	}
+
+	// Test a malformed line reporting its number.
+	loaded, err = store.LoadLines(strings.NewReader("a=1\n# comment\nbroken\nb=2\n"), 0)
+	if !errors.Is(err, ErrMalformedLine) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected ErrMalformedLine on line 3, but got %v", err)
+	}
+	if loaded != 1 {
+		t.Errorf("Expected 1 entry loaded before the error, but got %d", loaded)
+	}
+	if _, err := store.Get("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected lines after the error not to be loaded, but got %v", err)
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// GetOrCompute returns the value stored under key. On a miss it computes the value with fn,
+// stores it with the given TTL and returns it. Errors from fn are returned and nothing is stored.
+// Concurrent misses on the same key are serialized with LockKey, so fn runs once and the
+// other callers get its stored value. The loader set with WithLoader is not consulted. The
+// key is scoped to the tenant of ctx, as with GetContext.
+func (s *Storage) GetOrCompute(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) (interface{}, error)) (value interface{}, err error) {
+	key = s.tenantKey(ctx, key)
+	defer wrapOpError("GetOrCompute", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return nil, err
+	}
+	// Look the key up with GetInto rather than Get, so a miss runs only fn and not the
+	// loader set with WithLoader.
+	if s.GetInto(key, &value) {
+		return value, nil
+	}
+
+	unlock := s.LockKey(key)
+	defer unlock()
+	if s.GetInto(key, &value) {
+		return value, nil
+	}
+
+	err = s.load(ctx, func() error {
+		var err error
+		value, err = fn(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Set(key, value, ttl); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Once returns the value stored under key, building it with build and storing it without
+// expiration on the first call, for lazy singletons that are expensive to build. Concurrent
+// first callers are serialized with LockKey, so build runs once and they all share its
+// value. Unlike GetOrCompute, build is not retried with WithLoaderRetry and the value is
+// stored synchronously even with WithWriteCoalescing. If build fails, its error is returned,
+// nothing is stored and the next call builds again. Once the key is deleted, evicted or
+// cleared, the next call builds a new value. The loader set with WithLoader is not consulted.
+func (s *Storage) Once(key string, build func() (interface{}, error)) (value interface{}, err error) {
+	defer wrapOpError("Once", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := s.validateKeyAndTTL(key, 0); err != nil {
+		return nil, err
+	}
+	// As in GetOrCompute, GetInto keeps the loader set with WithLoader out of the lookups.
+	if s.GetInto(key, &value) {
+		return value, nil
+	}
+
+	unlock := s.LockKey(key)
+	defer unlock()
+	if s.GetInto(key, &value) {
+		return value, nil
+	}
+
+	value, err = build()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.set(context.Background(), key, value, 0, false); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Source tells where a value returned by GetDetailed came from.
+type Source int
+
+const (
+	// SourceMiss means no value was returned.
+	SourceMiss Source = iota
+	// SourceHit means the value came from a live entry.
+	SourceHit
+	// SourceLoaded means the value was just loaded by the loader set with WithLoader.
+	SourceLoaded
+	// SourceStale means the value came from an entry expired within the stale window set
+	// with WithStaleWindow.
+	SourceStale
+)
+
+// GetDetailed is like Get, but also reports which path served the read: a live entry, the
+// loader, a stale entry, or none of them on error.
+func (s *Storage) GetDetailed(key string) (value interface{}, source Source, err error) {
+	defer wrapOpError("GetDetailed", key, &err)
+	return s.getDetailed(context.Background(), key)
+}
+
+// getDetailed implements GetDetailed without wrapping errors. A load on a miss is passed ctx.
+func (s *Storage) getDetailed(ctx context.Context, key string) (interface{}, Source, error) {
+	value, _, source, err := s.getEntry(ctx, key)
+	return value, source, err
+}
+
+// getEntry is like getDetailed, but also returns the item a hit or stale read was served
+// from, as read under the same lock as its value, or nil for a loaded value.
+func (s *Storage) getEntry(ctx context.Context, key string) (interface{}, *item, Source, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, nil, SourceMiss, err
+	}
+	s.markActive()
+	if err := s.rlock(); err != nil {
+		return nil, nil, SourceMiss, err
+	}
+	item, exists := s.lookup(key)
+	s.mu.RUnlock()
+
+	now := s.now()
+	if exists && !item.isExpiredAt(now) {
+		value, err := s.hit(item, now)
+		if err != nil {
+			return nil, nil, SourceMiss, err
+		}
+		s.maybeExtend(key, item, now)
+		s.maybeRefreshAhead(key, item, now)
+		return value, item, SourceHit, nil
+	}
+	if exists && !s.isReclaimable(item, now) {
+		value, err := s.hit(item, now)
+		if err != nil {
+			return nil, nil, SourceMiss, err
+		}
+		s.refresh(key)
+		return value, item, SourceStale, nil
+	}
+
+	atomic.AddUint64(&s.misses, 1)
+	if s.loader != nil {
+		if s.nonBlockingLoad {
+			if !s.startLoad(key) {
+				if exists {
+					value, err := s.readValue(item)
+					if err != nil {
+						return nil, nil, SourceMiss, err
+					}
+					return value, item, SourceStale, nil
+				}
+				return nil, nil, SourceMiss, keyNotFound(key)
+			}
+			defer s.finishLoad(key)
+		}
+		value, err := s.loadValue(ctx, key)
+		if err != nil {
+			return nil, nil, SourceMiss, err
+		}
+		return value, nil, SourceLoaded, nil
+	}
+	if !exists {
+		return nil, nil, SourceMiss, keyNotFound(key)
+	}
+	s.removeExpiredItem(key, item)
+	return nil, nil, SourceMiss, keyExpired(key)
+}
+
+// hit counts a read served from item and returns its value.
+func (s *Storage) hit(item *item, now time.Time) (interface{}, error) {
+	atomic.AddUint64(&s.hits, 1)
+	if s.trackAccess || s.extendThreshold > 0 {
+		item.recordAccess(now)
+	}
+	s.markUsed(item, now)
+	return s.readValue(item)
+}
+
+// isReclaimable reports whether item has expired and is past the stale window, so it can
+// no longer be served and may be removed.
+func (s *Storage) isReclaimable(item *item, now time.Time) bool {
+	return item.isExpiredAt(now.Add(-s.staleWindow))
+}
+
+// loadValue loads the value of key with the loader, passing it ctx, and stores it with
+// DefaultTTL.
+func (s *Storage) loadValue(ctx context.Context, key string) (interface{}, error) {
+	var value interface{}
+	err := s.load(ctx, func() error {
+		var err error
+		value, err = s.loader(ctx, key)
+		return err
+	})
+	s.recordLoad(key, err)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.set(context.Background(), key, value, DefaultTTL, false); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// loadFailure is the last error of the loader for a key.
+type loadFailure struct {
+	err error
+	at  time.Time
+}
+
+// recordLoad records err as the last loader error of key, or clears it if err is nil.
+func (s *Storage) recordLoad(key string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.loadErrors, key)
+		return
+	}
+	if s.loadErrors == nil {
+		s.loadErrors = make(map[string]loadFailure)
+	}
+	s.loadErrors[key] = loadFailure{err: err, at: s.now()}
+}
+
+// LastError returns the last error the loader set with WithLoader returned for key and
+// when it happened, for diagnosing keys that fail to load. The last return value is false
+// if the key has no recorded error. An error is kept until the key loads successfully or
+// Reset is called, so storage holds one error per failing key; keys that keep failing
+// without ever loading stay recorded.
+func (s *Storage) LastError(key string) (error, time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	failure, exists := s.loadErrors[key]
+	return failure.err, failure.at, exists
+}
+
+// maybeRefreshAhead refreshes key in the background if item, the live item served for it,
+// expires within the refresh-ahead window, counting the read for Stats.
+func (s *Storage) maybeRefreshAhead(key string, item *item, now time.Time) {
+	if s.refreshAhead <= 0 || s.loader == nil || item.expiration.IsZero() || item.expiration.Sub(now) >= s.refreshAhead {
+		return
+	}
+	atomic.AddUint64(&s.refreshAheadServed, 1)
+	if s.refresh(key) {
+		atomic.AddUint64(&s.refreshAheadTriggered, 1)
+	}
+}
+
+// refresh reloads a stale or expiring key in the background, unless a refresh of it is
+// already running or there is no loader, and reports whether it started one. Refresh
+// errors are logged, and the current value is served until it can no longer be.
+func (s *Storage) refresh(key string) bool {
+	if s.loader == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	if _, running := s.refreshing[key]; running {
+		s.mu.Unlock()
+		return false
+	}
+	if s.refreshing == nil {
+		s.refreshing = make(map[string]struct{})
+	}
+	s.refreshing[key] = struct{}{}
+	s.mu.Unlock()
+
+	s.safeGo(func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.refreshing, key)
+			s.mu.Unlock()
+		}()
+		if _, err := s.loadValue(context.Background(), key); err != nil {
+			s.logger.Printf("Remo: [Refresh] key %q: %v", key, err)
+		}
+	})
+	return true
+}
+
+// startLoad marks a load of key by Get as running with WithNonBlockingLoad, unless a load
+// or refresh of it is already running, and reports whether it did.
+func (s *Storage) startLoad(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, running := s.loading[key]; running {
+		return false
+	}
+	if _, running := s.refreshing[key]; running {
+		return false
+	}
+	if s.loading == nil {
+		s.loading = make(map[string]struct{})
+	}
+	s.loading[key] = struct{}{}
+	return true
+}
+
+// finishLoad marks the load of key started by startLoad as done.
+func (s *Storage) finishLoad(key string) {
+	s.mu.Lock()
+	delete(s.loading, key)
+	s.mu.Unlock()
+}
+
+// loadExists asks the exists loader whether key exists at the origin and caches the answer as a marker.
+func (s *Storage) loadExists(key string) (bool, error) {
+	var found bool
+	err := s.load(context.Background(), func() error {
+		var err error
+		found, err = s.existsLoader(key)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	expiration := s.calculateExpiration(s.existsTTL)
+	s.mu.Lock()
+	// A value stored while the loader ran is more authoritative than its answer.
+	if _, exists := s.data[key]; !exists {
+		s.markers[key] = newItem(found, expiration)
+	}
+	s.mu.Unlock()
+	return found, nil
+}
+
+// load runs a loader call, retrying it with WithLoaderRetry. Between attempts it waits for
+// an exponential backoff with jitter, or returns the error of ctx once it is done.
+func (s *Storage) load(ctx context.Context, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.loadOnce(ctx, call)
+		if err == nil || attempt >= s.loadAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		backoff := s.loadBackoff << (attempt - 1)
+		if backoff > 0 {
+			backoff += time.Duration(s.randInt63n(int64(backoff)))
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// loadOnce runs a loader call once a concurrent load slot is available.
+func (s *Storage) loadOnce(ctx context.Context, call func() error) error {
+	if s.loadSem != nil {
+		select {
+		case s.loadSem <- struct{}{}:
+			defer func() { <-s.loadSem }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return call()
+}
@@ -0,0 +1,571 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStorage_HasExistsLoader(t *testing.T) {
+	calls := 0
+	store := New(WithExistsLoader(func(key string) (bool, error) {
+		calls++
+		return key == "remoteKey", nil
+	}))
+
+	// Test a miss consulting the exists loader.
+	found, err := store.Has("remoteKey")
+	if err != nil {
+		t.Fatalf("Has() failed: %v", err)
+	}
+	if !found {
+		t.Errorf("Expected remoteKey to exist")
+	}
+
+	// Test the positive answer being cached.
+	found, _ = store.Has("remoteKey")
+	if !found || calls != 1 {
+		t.Errorf("Expected cached positive answer after 1 call, got %v after %d calls", found, calls)
+	}
+
+	// Test the negative answer being cached.
+	store.Has("missingKey")
+	found, _ = store.Has("missingKey")
+	if found || calls != 2 {
+		t.Errorf("Expected cached negative answer after 2 calls, got %v after %d calls", found, calls)
+	}
+
+	// Test a marker never being visible to Get.
+	_, err = store.Get("remoteKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+
+	// Test Set overriding a negative marker.
+	err = store.Set("missingKey", "value", 0)
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	found, _ = store.Has("missingKey")
+	if !found || calls != 2 {
+		t.Errorf("Expected stored value to be found without a loader call, got %v after %d calls", found, calls)
+	}
+}
+
+func TestStorage_HasExistsLoaderError(t *testing.T) {
+	errOrigin := errors.New("origin unavailable")
+	calls := 0
+	store := New(WithExistsLoader(func(key string) (bool, error) {
+		calls++
+		return false, errOrigin
+	}), WithExistsTTL(time.Minute))
+
+	_, err := store.Has("key")
+	if !errors.Is(err, errOrigin) {
+		t.Errorf("Expected origin error, but got %v", err)
+	}
+
+	// Test errors not being cached.
+	store.Has("key")
+	if calls != 2 {
+		t.Errorf("Expected 2 loader calls, but got %d", calls)
+	}
+}
+
+func TestStorage_HasExistsTTL(t *testing.T) {
+	calls := 0
+	store := New(WithExistsLoader(func(key string) (bool, error) {
+		calls++
+		return true, nil
+	}), WithExistsTTL(100*time.Millisecond))
+
+	store.Has("key")
+	time.Sleep(200 * time.Millisecond)
+	store.Has("key")
+	if calls != 2 {
+		t.Errorf("Expected expired marker to be reloaded, got %d calls", calls)
+	}
+}
+
+func TestStorage_GetOrCompute(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	calls := 0
+	compute := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return "computedValue", nil
+	}
+
+	// Test a miss computing and storing the value.
+	value, err := store.GetOrCompute(ctx, "key", time.Second, compute)
+	if err != nil {
+		t.Fatalf("GetOrCompute() failed: %v", err)
+	}
+	if value != "computedValue" {
+		t.Errorf("Expected computedValue, but got %v", value)
+	}
+
+	// Test a hit returning the stored value.
+	store.GetOrCompute(ctx, "key", time.Second, compute)
+	if calls != 1 {
+		t.Errorf("Expected 1 compute call, but got %d", calls)
+	}
+
+	// Test compute errors being returned and nothing being stored.
+	errCompute := errors.New("compute failed")
+	_, err = store.GetOrCompute(ctx, "failingKey", time.Second, func(ctx context.Context) (interface{}, error) {
+		return nil, errCompute
+	})
+	if !errors.Is(err, errCompute) {
+		t.Errorf("Expected compute error, but got %v", err)
+	}
+	if _, err := store.Get("failingKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+}
+
+// Test that a miss in GetOrCompute runs only fn and never the loader set with WithLoader.
+func TestStorage_GetOrComputeSkipsLoader(t *testing.T) {
+	var loads int32
+	store := New(WithLoader(func(key string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return "loadedValue", nil
+	}))
+
+	value, err := store.GetOrCompute(context.Background(), "key", 0, func(ctx context.Context) (interface{}, error) {
+		return "computedValue", nil
+	})
+	if err != nil || value != "computedValue" {
+		t.Errorf("Expected computedValue, got %v, %v", value, err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 0 {
+		t.Errorf("Expected no loader calls, but got %d", n)
+	}
+}
+
+// Test that Once builds a value once under concurrent callers, shares it and retries on errors.
+func TestStorage_Once(t *testing.T) {
+	store := New()
+
+	type singleton struct{ n int }
+	var builds int32
+	build := func() (interface{}, error) {
+		atomic.AddInt32(&builds, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &singleton{n: 1}, nil
+	}
+
+	const numCallers = 20
+	results := make([]interface{}, numCallers)
+	var wg sync.WaitGroup
+	for i := 0; i < numCallers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, err := store.Once("singleton", build)
+			if err != nil {
+				t.Errorf("Once() failed: %v", err)
+			}
+			results[i] = value
+		}(i)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&builds); n != 1 {
+		t.Errorf("Expected 1 build, but got %d", n)
+	}
+	for i := range results {
+		if results[i] != results[0] {
+			t.Fatalf("Expected every caller to share %p, but got %p", results[0], results[i])
+		}
+	}
+	if ttl, err := store.TTL("singleton"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected %v, but got %v, %v", NoExpiration, ttl, err)
+	}
+
+	// Test a failed build storing nothing and being retried.
+	errBuild := errors.New("build failed")
+	if _, err := store.Once("failing", func() (interface{}, error) { return nil, errBuild }); !errors.Is(err, errBuild) {
+		t.Errorf("Expected %v, but got %v", errBuild, err)
+	}
+	if value, err := store.Once("failing", func() (interface{}, error) { return "built", nil }); err != nil || value != "built" {
+		t.Errorf("Expected built, but got %v, %v", value, err)
+	}
+}
+
+// Test that Once builds and stores a permanent value even with a loader configured.
+func TestStorage_OnceSkipsLoader(t *testing.T) {
+	var loads int32
+	store := New(WithLoader(func(key string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return "loadedValue", nil
+	}))
+
+	value, err := store.Once("singleton", func() (interface{}, error) { return "built", nil })
+	if err != nil || value != "built" {
+		t.Errorf("Expected built, got %v, %v", value, err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 0 {
+		t.Errorf("Expected no loader calls, but got %d", n)
+	}
+	if ttl, err := store.TTL("singleton"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected %v, but got %v, %v", NoExpiration, ttl, err)
+	}
+}
+
+// Test that Get returns at once while another Get is loading the same key.
+func TestStorage_NonBlockingLoad(t *testing.T) {
+	release := make(chan struct{})
+	store := New(WithNonBlockingLoad(), WithLoader(func(key string) (interface{}, error) {
+		<-release
+		return "loaded", nil
+	}))
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Set("staleKey", "old", time.Second)
+	now = now.Add(time.Minute)
+
+	loading := func(key string) bool {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		_, running := store.loading[key]
+		return running
+	}
+
+	done := make(chan struct{})
+	for _, key := range []string{"key", "staleKey"} {
+		go func(key string) {
+			store.Get(key)
+			done <- struct{}{}
+		}(key)
+		waitFor(t, func() bool { return loading(key) })
+	}
+
+	// Test a key without a value returning a miss.
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected %v, but got %v", ErrKeyNotFound, err)
+	}
+
+	// Test a key with an expired value returning it.
+	value, source, err := store.GetDetailed("staleKey")
+	if err != nil || value != "old" || source != SourceStale {
+		t.Errorf("Expected old from SourceStale, but got %v from %v, %v", value, source, err)
+	}
+
+	close(release)
+	<-done
+	<-done
+	if value, err := store.Get("key"); err != nil || value != "loaded" {
+		t.Errorf("Expected loaded, but got %v, %v", value, err)
+	}
+}
+
+func TestStorage_MaxConcurrentLoads(t *testing.T) {
+	const maxLoads = 3
+	store := New(WithMaxConcurrentLoads(maxLoads))
+
+	var inFlight, peak int32
+	compute := func(ctx context.Context) (interface{}, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.GetOrCompute(context.Background(), fmt.Sprintf("key%d", i), 0, compute)
+			if err != nil {
+				t.Errorf("GetOrCompute() failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak > maxLoads {
+		t.Errorf("Expected at most %d concurrent loads, but got %d", maxLoads, peak)
+	}
+}
+
+func TestStorage_MaxConcurrentLoadsCancel(t *testing.T) {
+	store := New(WithMaxConcurrentLoads(1))
+
+	// Hold the only load slot.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go store.GetOrCompute(context.Background(), "slowKey", 0, func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return "value", nil
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := store.GetOrCompute(ctx, "waitingKey", 0, func(ctx context.Context) (interface{}, error) {
+		return "value", nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestStorage_GetDetailed(t *testing.T) {
+	errOrigin := errors.New("origin unavailable")
+	var loads int32
+	store := New(
+		WithLoader(func(key string) (interface{}, error) {
+			if key == "failingKey" {
+				return nil, errOrigin
+			}
+			return fmt.Sprintf("%s-v%d", key, atomic.AddInt32(&loads, 1)), nil
+		}),
+		WithTTLFunc(func(key string, value interface{}) time.Duration { return time.Minute }),
+		WithStaleWindow(time.Minute),
+	)
+	var mu sync.Mutex
+	now := time.Now()
+	store.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+	}
+
+	// Test a miss being loaded.
+	value, source, err := store.GetDetailed("key")
+	if err != nil || value != "key-v1" || source != SourceLoaded {
+		t.Errorf("Expected key-v1 from the loader, got %v, %v, %v", value, source, err)
+	}
+
+	// Test a live entry being a hit.
+	value, source, err = store.GetDetailed("key")
+	if err != nil || value != "key-v1" || source != SourceHit {
+		t.Errorf("Expected key-v1 from a hit, got %v, %v, %v", value, source, err)
+	}
+
+	// Test an expired entry within the stale window being served while it is refreshed.
+	advance(90 * time.Second)
+	value, source, err = store.GetDetailed("key")
+	if err != nil || value != "key-v1" || source != SourceStale {
+		t.Errorf("Expected stale key-v1, got %v, %v, %v", value, source, err)
+	}
+	waitFor(t, func() bool {
+		value, source, _ := store.GetDetailed("key")
+		return value == "key-v2" && source == SourceHit
+	})
+
+	// Test an entry past the stale window being loaded again.
+	advance(3 * time.Minute)
+	value, source, err = store.GetDetailed("key")
+	if err != nil || value != "key-v3" || source != SourceLoaded {
+		t.Errorf("Expected key-v3 from the loader, got %v, %v, %v", value, source, err)
+	}
+
+	// Test a loader error being a miss.
+	value, source, err = store.GetDetailed("failingKey")
+	if !errors.Is(err, errOrigin) || value != nil || source != SourceMiss {
+		t.Errorf("Expected a miss with errOrigin, got %v, %v, %v", value, source, err)
+	}
+
+	// Test a miss without a loader.
+	store = New()
+	if _, source, err := store.GetDetailed("missingKey"); source != SourceMiss || !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected a miss with ErrKeyNotFound, got %v, %v", source, err)
+	}
+}
+
+func TestStorage_StaleWindowCleanup(t *testing.T) {
+	store := New(WithStaleWindow(time.Minute))
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Set("key", "value", time.Second)
+
+	// Test cleanup keeping entries within the stale window.
+	now = now.Add(30 * time.Second)
+	store.PurgeExpired()
+	if value, source, err := store.GetDetailed("key"); err != nil || value != "value" || source != SourceStale {
+		t.Errorf("Expected a stale value, got %v, %v, %v", value, source, err)
+	}
+
+	// Test cleanup removing entries past the stale window.
+	now = now.Add(time.Minute)
+	store.PurgeExpired()
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+}
+
+func TestStorage_LastError(t *testing.T) {
+	errOrigin := errors.New("origin unavailable")
+	failing := true
+	store := New(WithLoader(func(key string) (interface{}, error) {
+		if failing {
+			return nil, errOrigin
+		}
+		return "value", nil
+	}))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	// Test a key without a failed load having no error.
+	if _, _, exists := store.LastError("key"); exists {
+		t.Errorf("Expected no error before any load")
+	}
+
+	// Test a failed load being recorded with its time.
+	if _, err := store.Get("key"); !errors.Is(err, errOrigin) {
+		t.Errorf("Expected errOrigin, but got %v", err)
+	}
+	err, at, exists := store.LastError("key")
+	if !exists || err != errOrigin || !at.Equal(now) {
+		t.Errorf("Expected errOrigin at %v, got %v at %v (%v)", now, err, at, exists)
+	}
+
+	// Test a successful load clearing the error.
+	failing = false
+	now = now.Add(time.Minute)
+	if value, err := store.Get("key"); err != nil || value != "value" {
+		t.Errorf("Expected value, got %v, %v", value, err)
+	}
+	if _, _, exists := store.LastError("key"); exists {
+		t.Errorf("Expected the error to be cleared after a successful load")
+	}
+}
+
+func TestStorage_LoaderRetry(t *testing.T) {
+	errTransient := errors.New("transient failure")
+	var calls int32
+	store := New(
+		WithLoader(func(key string) (interface{}, error) {
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				return nil, errTransient
+			}
+			return "value", nil
+		}),
+		WithLoaderRetry(3, time.Millisecond),
+	)
+
+	// Test a loader failing twice then succeeding being retried.
+	if value, err := store.Get("key"); err != nil || value != "value" {
+		t.Errorf("Expected value, got %v, %v", value, err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 loader calls, but got %d", calls)
+	}
+
+	// Test the loaded value being cached.
+	if value, err := store.Get("key"); err != nil || value != "value" || calls != 3 {
+		t.Errorf("Expected the cached value without a load, got %v, %v after %d calls", value, err, calls)
+	}
+
+	// Test the last error being returned once attempts run out, with nothing cached.
+	atomic.StoreInt32(&calls, -10)
+	if _, err := store.Get("otherKey"); !errors.Is(err, errTransient) {
+		t.Errorf("Expected errTransient, but got %v", err)
+	}
+	if calls != -7 {
+		t.Errorf("Expected 3 loader calls, but got %d", calls+10)
+	}
+	store.mu.RLock()
+	_, cached := store.data["otherKey"]
+	store.mu.RUnlock()
+	if cached {
+		t.Errorf("Expected nothing to be cached after the final failure")
+	}
+
+	// Test GetOrCompute stopping between attempts once its context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	computes := 0
+	_, err := store.GetOrCompute(ctx, "computedKey", 0, func(ctx context.Context) (interface{}, error) {
+		computes++
+		cancel()
+		return nil, errTransient
+	})
+	if !errors.Is(err, errTransient) || computes != 1 {
+		t.Errorf("Expected errTransient after 1 attempt, got %v after %d", err, computes)
+	}
+}
+
+func TestStorage_RefreshAhead(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	store := New(
+		WithLoader(func(key string) (interface{}, error) {
+			if n := atomic.AddInt32(&loads, 1); n > 1 {
+				<-release
+				return fmt.Sprintf("v%d", n), nil
+			}
+			return "v1", nil
+		}),
+		WithTTLFunc(func(key string, value interface{}) time.Duration { return time.Minute }),
+		WithRefreshAhead(10*time.Second),
+	)
+	var mu sync.Mutex
+	now := time.Now()
+	store.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	// Test reads outside the window not counting.
+	store.Get("key")
+	store.Get("key")
+	if stats := store.Stats(); stats.RefreshAheadServed != 0 || stats.RefreshAheadTriggered != 0 {
+		t.Errorf("Expected no refresh-ahead outside the window, but got %+v", stats)
+	}
+
+	// Test reads within the window serving the current value and triggering one refresh.
+	mu.Lock()
+	now = now.Add(55 * time.Second)
+	mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if value, err := store.Get("key"); err != nil || value != "v1" {
+			t.Errorf("Expected v1 while refreshing, got %v, %v", value, err)
+		}
+	}
+	if stats := store.Stats(); stats.RefreshAheadServed != 2 || stats.RefreshAheadTriggered != 1 {
+		t.Errorf("Expected 2 served and 1 triggered, but got %+v", stats)
+	}
+
+	// Test the refreshed value replacing the old one.
+	close(release)
+	waitFor(t, func() bool {
+		value, _ := store.Get("key")
+		return value == "v2"
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// ErrLockTimeout is returned when the lock of storage cannot be acquired within the
+// timeout set by WithLockTimeout.
+var ErrLockTimeout = errors.New("lock acquisition timed out")
+
+// maxLockPollInterval caps the wait between attempts to acquire the lock under a timeout.
+const maxLockPollInterval = time.Millisecond
+
+// lock acquires the write lock, giving up with ErrLockTimeout after the lock timeout.
+func (s *Storage) lock() error {
+	return s.acquire(s.mu.TryLock, s.mu.Lock)
+}
+
+// rlock acquires the read lock, giving up with ErrLockTimeout after the lock timeout.
+func (s *Storage) rlock() error {
+	return s.acquire(s.mu.TryRLock, s.mu.RLock)
+}
+
+// acquire acquires a lock with lock or, if a lock timeout is set, by polling tryLock until
+// it succeeds or the timeout passes. The timeout is measured on the wall clock, not with
+// s.now, so it holds even when the storage clock is stopped.
+func (s *Storage) acquire(tryLock func() bool, lock func()) error {
+	if s.lockTimeout <= 0 {
+		lock()
+		return nil
+	}
+	deadline := time.Now().Add(s.lockTimeout)
+	for wait := time.Microsecond; !tryLock(); {
+		if !time.Now().Before(deadline) {
+			s.logLockTimeout()
+			return ErrLockTimeout
+		}
+		time.Sleep(wait)
+		if wait < maxLockPollInterval {
+			wait *= 2
+		}
+	}
+	return nil
+}
+
+// logLockTimeout logs the stacks of all goroutines, which include the one holding the lock.
+func (s *Storage) logLockTimeout() {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 16<<20 {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	s.logger.Printf("Remo: [Lock] not acquired within %v, goroutines:\n%s", s.lockTimeout, buf)
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorage_LockTimeout(t *testing.T) {
+	logger := &recordingLogger{}
+	store := New(WithLockTimeout(20*time.Millisecond), WithLogger(logger))
+	store.Set("key", "value", 0)
+
+	// Test operations timing out while the lock is held.
+	store.mu.Lock()
+	if err := store.Set("key", "other", 0); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from Set, but got %v", err)
+	}
+	if _, err := store.Get("key"); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from Get, but got %v", err)
+	}
+	store.mu.Unlock()
+
+	// Test the timeouts being logged with goroutine stacks.
+	logger.mu.Lock()
+	if len(logger.messages) != 2 || !strings.Contains(logger.messages[0], "goroutine") {
+		t.Errorf("Expected 2 messages with stacks, but got %v", logger.messages)
+	}
+	logger.mu.Unlock()
+
+	// Test operations succeeding once the lock is free, including after a short wait.
+	store.mu.RLock()
+	time.AfterFunc(5*time.Millisecond, store.mu.RUnlock)
+	if err := store.Set("key", "other", 0); err != nil {
+		t.Errorf("Expected Set to succeed, but got %v", err)
+	}
+	if value, err := store.Get("key"); err != nil || value != "other" {
+		t.Errorf("Expected other, got %v, %v", value, err)
+	}
+}
+
+func TestStorage_LockTimeoutOtherOperations(t *testing.T) {
+	// Created first, other is locked first by MoveTo.
+	other := New()
+	store := New(WithLockTimeout(10*time.Millisecond), WithLogger(&recordingLogger{}))
+	store.Set("counter", int64(1), 0)
+
+	// Test operations beyond Get and Set timing out while the lock is held.
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, err := store.Increment("counter", 1, 0); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from Increment, but got %v", err)
+	}
+	if _, err := store.CompareAndSwap("counter", int64(1), int64(2)); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from CompareAndSwap, but got %v", err)
+	}
+	if err := store.RingPush("ring", "value", 2, 0); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from RingPush, but got %v", err)
+	}
+	if err := store.MoveTo(other, "counter"); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from MoveTo, but got %v", err)
+	}
+	if _, errs := store.GetSlice([]string{"counter"}); !errors.Is(errs[0], ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout from GetSlice, but got %v", errs[0])
+	}
+
+	// Test MoveTo releasing the lock of the other storage when it times out.
+	if !other.mu.TryLock() {
+		t.Errorf("Expected the other storage to be unlocked")
+	} else {
+		other.mu.Unlock()
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+// MoveTo moves the live item stored under key to dst, preserving its value and expiration,
+// and removes it from s. Both storages are locked for the whole move, always in the order
+// they were created, so goroutines moving keys in opposite directions cannot deadlock.
+func (s *Storage) MoveTo(dst *Storage, key string) (err error) {
+	defer wrapOpError("MoveTo", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if err := dst.checkOpen(); err != nil {
+		return err
+	}
+	first, second := s, dst
+	if dst.id < s.id {
+		first, second = dst, s
+	}
+	if err := first.lock(); err != nil {
+		return err
+	}
+	defer first.mu.Unlock()
+	if second != first {
+		if err := second.lock(); err != nil {
+			return err
+		}
+		defer second.mu.Unlock()
+	}
+
+	item, exists := s.data[key]
+	if !exists {
+		return keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		return keyExpired(key)
+	}
+	if dst == s {
+		return nil
+	}
+	if err := dst.tryMakeRoom(key); err != nil {
+		return err
+	}
+	if err := dst.checkWritable(key); err != nil {
+		return err
+	}
+
+	stored, err := dst.reencode(s, item.value)
+	if err != nil {
+		return err
+	}
+
+	moved := item.clone()
+	moved.value = stored
+	dst.storeItem(key, moved)
+	s.dropPendingWrite(key)
+	s.removeItem(key)
+	return nil
+}
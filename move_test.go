@@ -0,0 +1,99 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage_MoveTo(t *testing.T) {
+	src := New()
+	dst := New()
+
+	src.Set("key", "value", time.Minute)
+	expiration := src.data["key"].expiration
+
+	err := src.MoveTo(dst, "key")
+	if err != nil {
+		t.Fatalf("MoveTo() failed: %v", err)
+	}
+
+	// Test the value and expiration being transferred.
+	value, err := dst.Get("key")
+	if err != nil || value != "value" {
+		t.Errorf("Expected value in destination, got %v, %v", value, err)
+	}
+	if !dst.data["key"].expiration.Equal(expiration) {
+		t.Errorf("Expected expiration %v, but got %v", expiration, dst.data["key"].expiration)
+	}
+
+	// Test the source entry being removed.
+	if _, err := src.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound in source, but got %v", err)
+	}
+
+	// Test missing and expired keys.
+	if err := src.MoveTo(dst, "key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	src.Set("expiredKey", "value", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if err := src.MoveTo(dst, "expiredKey"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+
+	// Test moving a key to its own storage.
+	dst.Set("selfKey", "value", 0)
+	if err := dst.MoveTo(dst, "selfKey"); err != nil {
+		t.Errorf("Expected moving to the same storage to succeed, but got %v", err)
+	}
+	if _, err := dst.Get("selfKey"); err != nil {
+		t.Errorf("Expected selfKey to remain, but got %v", err)
+	}
+}
+
+func TestStorage_MoveToOppositeDirections(t *testing.T) {
+	a := New()
+	b := New()
+	const numKeys = 1000
+
+	a.Set("key", "value", 0)
+
+	// Move keys back and forth concurrently; a lock-ordering bug would deadlock here.
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*Storage{{a, b}, {b, a}} {
+		wg.Add(1)
+		go func(src, dst *Storage) {
+			defer wg.Done()
+			for i := 0; i < numKeys; i++ {
+				src.MoveTo(dst, "key")
+			}
+		}(pair[0], pair[1])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("MoveTo deadlocked")
+	}
+}
@@ -0,0 +1,596 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures a Storage created by New.
+type Option func(*Storage)
+
+// Logger is the interface storage uses to report problems, such as recovered panics and
+// overrunning cleanup passes. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger storage reports problems to. It defaults to log.Default().
+func WithLogger(logger Logger) Option {
+	return func(s *Storage) {
+		s.logger = logger
+	}
+}
+
+// WithTenantFunc sets the function deriving a tenant from a context, such as a tenant ID
+// stored as a context value. GetContext, SetContext, DeleteContext and GetOrCompute then
+// transparently scope keys to the tenant of their context, so tenants reading the same
+// logical key never collide. The tenant is encoded with its length, so no tenant can forge
+// another's keys. An empty tenant leaves keys unscoped. Methods without a context, such as
+// Get and Reset, operate on the raw keys of all tenants.
+func WithTenantFunc(fn func(ctx context.Context) string) Option {
+	return func(s *Storage) {
+		s.tenantFunc = fn
+	}
+}
+
+// WithExistsLoader sets a loader consulted by Has when a key is not stored locally.
+// It lets existence checks ask the origin without fetching the full value.
+//
+// The answer is cached as a lightweight marker kept apart from stored values, so Get
+// never sees it. A negative marker acts as a negative cache: Has keeps reporting false
+// without calling the loader until the marker expires (see WithExistsTTL) or the key is
+// Set. Loader errors are returned by Has and are not cached.
+func WithExistsLoader(fn func(key string) (bool, error)) Option {
+	return func(s *Storage) {
+		s.existsLoader = fn
+	}
+}
+
+// WithLoader makes storage read-through: when Get misses, fn loads the value from the
+// origin, and the value is stored with DefaultTTL, so its lifetime follows WithTTLRules
+// and WithTTLFunc. Loader errors are returned by Get and nothing is stored. Loads count
+// towards WithMaxConcurrentLoads. Use WithLoaderContext for a loader that needs the context
+// of the read.
+func WithLoader(fn func(key string) (interface{}, error)) Option {
+	return func(s *Storage) {
+		s.loader = func(ctx context.Context, key string) (interface{}, error) {
+			return fn(key)
+		}
+	}
+}
+
+// WithLoaderContext is like WithLoader, but fn is also passed a context: the ctx of
+// GetContext, so it can honor its cancellation or read its tenant, and
+// context.Background() for Get and background refreshes. The key it is passed is already
+// scoped to the tenant. Once ctx is done, GetContext stops waiting for a load slot or a
+// retry backoff and returns the error of ctx.
+func WithLoaderContext(fn func(ctx context.Context, key string) (interface{}, error)) Option {
+	return func(s *Storage) {
+		s.loader = fn
+	}
+}
+
+// WithLoaderRetry makes failed loads of WithLoader, WithExistsLoader and GetOrCompute
+// retry up to attempts calls in total. Before the nth retry, it waits baseBackoff times
+// 2^(n-1), plus a random jitter of up to as much again, so a transient failure of the
+// origin does not surface as an error. Retries add their backoff to the latency of the
+// Get that triggered the load, and GetOrCompute stops retrying with its context's error
+// once the context is done. If the last attempt fails, its error is returned and nothing
+// is stored. Each attempt takes its own WithMaxConcurrentLoads slot.
+func WithLoaderRetry(attempts int, baseBackoff time.Duration) Option {
+	return func(s *Storage) {
+		s.loadAttempts = attempts
+		s.loadBackoff = baseBackoff
+	}
+}
+
+// WithRefreshAhead makes Get reload a live entry in the background with the loader set by
+// WithLoader when it expires in less than window, so hot keys are refreshed before they
+// expire instead of missing. The current value is returned meanwhile, and only one refresh
+// of a key runs at a time. Stats reports how often this happens.
+func WithRefreshAhead(window time.Duration) Option {
+	return func(s *Storage) {
+		s.refreshAhead = window
+	}
+}
+
+// WithTombstones records a tombstone with the key and time of every entry removed by a
+// delete, by expiring or by being evicted, and keeps it for ttl, so a change-data-capture
+// consumer can catch up on deletions with Tombstones after being offline for up to ttl.
+// Tombstones expire on their own after ttl, but until then each removal costs a key and a
+// timestamp of memory, so ttl bounds the memory used under heavy deletion. Entries cleared
+// by Reset and SoftReset are not recorded.
+func WithTombstones(ttl time.Duration) Option {
+	return func(s *Storage) {
+		s.tombstoneTTL = ttl
+	}
+}
+
+// WithNonBlockingLoad makes Get return at once instead of loading a key with the loader
+// set by WithLoader while a load or refresh of that key is already in progress. It returns
+// the expired value still held for the key, if any, reported as SourceStale by GetDetailed,
+// and ErrKeyNotFound otherwise. This trades latency for freshness: reads during a load may
+// see an old value or a miss rather than waiting for the new value.
+func WithNonBlockingLoad() Option {
+	return func(s *Storage) {
+		s.nonBlockingLoad = true
+	}
+}
+
+// WithStaleWindow keeps expired entries around for d after they expire and lets Get serve
+// them in the meantime, refreshing them with the loader set by WithLoader in the
+// background, one refresh per key at a time. Readers thus avoid waiting on the origin
+// while a value is being refreshed. Only Get and GetDetailed serve stale values; other
+// reads treat them as expired.
+func WithStaleWindow(d time.Duration) Option {
+	return func(s *Storage) {
+		s.staleWindow = d
+	}
+}
+
+// WithBloomFilter keeps a Bloom filter of stored keys, sized for expectedKeys keys at the
+// given false positive rate, so Get and Has can answer for keys that were never stored
+// without looking them up. Entries cannot be removed from a Bloom filter: deleted and
+// expired keys stay in it until Reset or Restore, and together with false positives they
+// fall through to the normal lookup. Its memory is about -ln(rate)/ln(2)² bits per expected
+// key, and it fills up, letting more keys through, when many more keys are stored. It is
+// disabled if expectedKeys is not positive or the rate is not between 0 and 1. A loader
+// set with WithLoader or WithExistsLoader is still consulted for keys the filter rules out.
+func WithBloomFilter(expectedKeys int, falsePositiveRate float64) Option {
+	return func(s *Storage) {
+		if expectedKeys > 0 && falsePositiveRate > 0 && falsePositiveRate < 1 {
+			s.bloom = newBloomFilter(expectedKeys, falsePositiveRate)
+		} else {
+			s.bloom = nil
+		}
+	}
+}
+
+// WithExistsTTL sets how long answers from the exists loader are cached.
+// A TTL of 0, the default, caches them until the key is Set, Deleted or the storage is Reset.
+func WithExistsTTL(ttl time.Duration) Option {
+	return func(s *Storage) {
+		s.existsTTL = ttl
+	}
+}
+
+// WithMaxConcurrentLoads caps the number of loader calls in flight across all keys at n,
+// protecting the origin from a burst of misses on many distinct keys. Callers beyond the
+// cap wait until a slot frees; GetOrCompute gives up waiting with the context's error when
+// its context is done. A value of 0 or less leaves loads unbounded.
+func WithMaxConcurrentLoads(n int) Option {
+	return func(s *Storage) {
+		if n > 0 {
+			s.loadSem = make(chan struct{}, n)
+		} else {
+			s.loadSem = nil
+		}
+	}
+}
+
+// WithAccessTracking enables per-key hit counts and last-access times, as reported by TopKeys.
+// Tracking costs two atomic writes on every successful Get, so it is off by default.
+func WithAccessTracking() Option {
+	return func(s *Storage) {
+		s.trackAccess = true
+	}
+}
+
+// WithLazyExpirationOnly disables the cleanup goroutine, making StartCleanup a no-op.
+// Expired entries are then removed only when read by Get or by an explicit PurgeExpired;
+// without reads they stay in memory. This suits short-lived processes where the goroutine
+// is pure overhead.
+func WithLazyExpirationOnly() Option {
+	return func(s *Storage) {
+		s.lazyOnly = true
+	}
+}
+
+// WithMaxEntries caps the number of entries storage holds at n, expired entries not yet
+// removed included. Writes of new keys beyond the cap follow the policy set by
+// WithFullPolicy, evicting by default; before applying it, they remove an expired entry if
+// they find one. Overwriting a stored key always succeeds, and Restore is not capped.
+// A value of 0, the default, leaves storage unbounded.
+func WithMaxEntries(n int) Option {
+	return func(s *Storage) {
+		s.maxEntries = n
+	}
+}
+
+// WithFullPolicy sets what writes of new keys do when storage holds the number of entries
+// set by WithMaxEntries: evict, reject or block. See FullPolicy.
+func WithFullPolicy(policy FullPolicy) Option {
+	return func(s *Storage) {
+		s.fullPolicy = policy
+	}
+}
+
+// WithBatchChunkSize makes DeleteMany and SetKeys release and reacquire the write lock
+// every n items, bounding how long a huge batch keeps readers and writers waiting. The
+// price is atomicity: other goroutines may observe or modify storage between chunks, and a
+// batch failing midway keeps the chunks applied so far. A value of 0, the default, applies
+// each batch under a single lock hold, all-or-nothing.
+func WithBatchChunkSize(n int) Option {
+	return func(s *Storage) {
+		s.batchChunk = n
+	}
+}
+
+// WithContainerCopy makes reads such as Get return a shallow copy of values that are
+// slices or maps, so callers appending to or modifying a returned container do not change
+// the stored one. Other values are returned as stored, which keeps reads of scalars free.
+// The copy is shallow: containers nested in the value, and anything it points to, are
+// still shared with storage. It has no effect with WithSerializedValues, whose reads
+// already return copies.
+func WithContainerCopy() Option {
+	return func(s *Storage) {
+		s.copyContainers = true
+	}
+}
+
+// WithEquality sets the comparator used by CompareAndSwap, DeleteIf and
+// WithSkipUnchangedWrites to match stored values, such as reflect.DeepEqual. By default
+// values are compared with ==, and comparing a value that is not comparable, such as a
+// slice or map, returns ErrNotComparable instead of panicking.
+func WithEquality(fn func(a, b interface{}) bool) Option {
+	return func(s *Storage) {
+		s.equality = fn
+	}
+}
+
+// WithSkipUnchangedWrites makes Set, SetWithGeneration and SetOnce skip writes that would
+// not change anything: if the key holds a live value equal to the new one, compared with
+// the function set by WithEquality or ==, and the expiration stays the same, the stored
+// entry is left untouched, so its access statistics are kept and the keys depending on it
+// are not invalidated. A write that changes the expiration still applies, which includes
+// any write with a positive TTL unless WithExpirationGranularity rounds it to the current
+// expiration. Values that cannot be compared are always written.
+func WithSkipUnchangedWrites() Option {
+	return func(s *Storage) {
+		s.skipUnchanged = true
+	}
+}
+
+// WithPerKeyWriteRate limits writes through Set and the operations built on it to limit
+// per window for each key, so a misbehaving producer writing one key in a tight loop cannot
+// thrash the lock or flood events. Each key has a token bucket holding up to limit tokens,
+// which refills at limit tokens per window: a key may take a burst of limit writes, and
+// then one write per window/limit. A write finding the bucket empty fails with
+// ErrWriteRateExceeded, or is dropped silently with WithDropRateLimitedWrites. Each bucket
+// costs a small allocation per written key and is removed with the key. Writes through
+// batch and read-modify-write operations such as SetKeys and Increment are not limited,
+// except Toggle.
+func WithPerKeyWriteRate(limit int, window time.Duration) Option {
+	return func(s *Storage) {
+		if limit > 0 && window > 0 {
+			s.writeRateLimit = limit
+			s.writeRateWindow = window
+		}
+	}
+}
+
+// WithDropRateLimitedWrites makes writes exceeding the rate set by WithPerKeyWriteRate be
+// dropped silently, reporting success without being applied, instead of failing with
+// ErrWriteRateExceeded.
+func WithDropRateLimitedWrites() Option {
+	return func(s *Storage) {
+		s.dropRateLimited = true
+	}
+}
+
+// WithCleanupPolicy registers a cleanup cadence for keys matching pattern, a glob as in
+// TTLRule: a background goroutine removes the expired entries of those keys every
+// interval, so namespaces of short-lived keys can be cleaned up aggressively without
+// scanning long-lived ones as often. It can be passed several times. A key matching
+// several policies is cleaned up by the most frequent one only. Each pass still walks
+// every entry to match the patterns, but only checks the expiration of the keys it owns.
+// The policies run on their own, whether or not StartCleanup is called, and Close stops
+// them. The cleanup goroutine of StartCleanup keeps checking every key at its own
+// interval, so give it the longest cadence wanted, or leave it off, and register
+// policies for the namespaces needing more. Policies are ignored with
+// WithLazyExpirationOnly.
+func WithCleanupPolicy(pattern string, interval time.Duration) Option {
+	return func(s *Storage) {
+		if interval > 0 {
+			s.cleanupPolicies = append(s.cleanupPolicies, cleanupPolicy{pattern: pattern, interval: interval})
+		}
+	}
+}
+
+// WithItemPool makes writes take their internal entries from a pool and return the entries
+// of writes that are never stored to it, to ease GC pressure under heavy write churn. These
+// are writes skipped by WithSkipUnchangedWrites, writes that fail when storing and writes
+// superseded by a later one with WithWriteCoalescing. Entries that were stored are never
+// reused, even once deleted, overwritten or expired, since a concurrent reader may still
+// hold them. Pooled entries are fully reset before they go back to the pool.
+func WithItemPool() Option {
+	return func(s *Storage) {
+		s.itemPool = newItemPool()
+	}
+}
+
+// WithAutoCleanup makes New start the cleanup goroutine with the given interval, as
+// StartCleanup does, so expired keys are collected without a separate call. New thus
+// spawns a goroutine, which StopCleanup or Close stop. It has no effect with
+// WithLazyExpirationOnly.
+func WithAutoCleanup(interval time.Duration) Option {
+	return func(s *Storage) {
+		s.autoCleanup = interval
+	}
+}
+
+// WithBatchExpireCallback sets a function called with the entries removed because they
+// expired. Each cleanup pass, PurgeExpired and Compact report all the entries they remove
+// in a single call; an expired entry removed as it is read, or to make room under
+// WithMaxEntries, is reported on its own. Entries deleted, evicted or cleared by Reset are
+// not reported; see WithEvictCallback. fn runs in its own goroutine, outside the lock, so
+// calls may overlap.
+func WithBatchExpireCallback(fn func(expired []KeyValue)) Option {
+	return func(s *Storage) {
+		s.onExpire = fn
+	}
+}
+
+// WithEvictCallback sets a function called with each entry storage drops without it being
+// deleted or expiring: live entries evicted to make room under PolicyEvict, and every entry
+// cleared by Reset or SoftReset. It suits values holding resources, such as connections,
+// that must be closed once storage lets go of them. fn runs in the background, outside the
+// lock; for Reset, a single goroutine calls it for each cleared entry after Reset returns.
+// Entries deleted, overwritten or expired are not reported; see WithBatchExpireCallback
+// for expired ones.
+func WithEvictCallback(fn func(key string, value interface{})) Option {
+	return func(s *Storage) {
+		s.onEvict = fn
+	}
+}
+
+// WithCleanupTimeBudget bounds each pass of the cleanup goroutine to roughly d of work,
+// so huge stores do not hold the write lock for long. A pass stops once the budget elapses
+// and the next one resumes where it left off, trading promptness of expiry for bounded
+// lock-hold time. PurgeExpired always runs a full pass.
+func WithCleanupTimeBudget(d time.Duration) Option {
+	return func(s *Storage) {
+		s.cleanupBudget = d
+	}
+}
+
+// WithCleanupStartJitter makes StartCleanup delay the cleanup ticker by a random offset in
+// [0, d), so instances started together do not all clean up at the same instant. Only the
+// phase of the ticker changes: the first pass runs after the offset plus one interval, and
+// passes then follow every interval as usual.
+func WithCleanupStartJitter(d time.Duration) Option {
+	return func(s *Storage) {
+		s.startJitter = d
+	}
+}
+
+// WithExpirationGranularity rounds expiration times up to a multiple of d, so entries set
+// around the same time share an expiration instant. Nothing expires before its TTL, but
+// entries may live up to d longer than requested. A value of 0, the default, keeps exact
+// expiration times.
+func WithExpirationGranularity(d time.Duration) Option {
+	return func(s *Storage) {
+		s.granularity = d
+	}
+}
+
+// WithMaxTTL caps the lifetime of entries at d: a larger TTL is clamped down to d, and a TTL
+// of 0 makes the entry expire after d instead of never. It applies wherever a TTL is turned
+// into an expiration time, including Touch and TTLs computed by WithTTLFunc; the expiration
+// granularity is applied after the clamp. A value of 0, the default, sets no limit.
+func WithMaxTTL(d time.Duration) Option {
+	return func(s *Storage) {
+		s.maxTTL = d
+	}
+}
+
+// WithLockTimeout is a debugging aid for deadlocks, such as a callback calling back into
+// storage while it is locked. With it, every operation that returns an error, such as Get,
+// Set, Increment, CompareAndSwap or MoveTo, gives up on acquiring the lock after d, logs
+// the stacks of all goroutines to the logger and returns ErrLockTimeout, instead of
+// hanging. Operations that cannot return an error, such as Delete, still wait for the lock;
+// GetSlice reports the timeout as the error of every key. Contended locks are acquired by
+// polling, which adds latency and CPU use, so it is meant for development only. A value of
+// 0, the default, disables it.
+func WithLockTimeout(d time.Duration) Option {
+	return func(s *Storage) {
+		s.lockTimeout = d
+	}
+}
+
+// WithAsyncDelete makes Delete queue deletions instead of applying them, for delete-heavy
+// workloads where every Delete taking the write lock contends with other traffic. A
+// background goroutine applies the queued deletions every interval under a single write
+// lock. Until then, a deleted key may still be read; a key set again after it was deleted
+// keeps its new value. The queue holds up to queueSize deletions, and Delete deletes
+// synchronously while it is full. Close applies the queued deletions and stops the goroutine.
+func WithAsyncDelete(queueSize int, interval time.Duration) Option {
+	return func(s *Storage) {
+		if queueSize > 0 && interval > 0 {
+			s.deleteQueue = make(chan queuedDelete, queueSize)
+			s.deleteInterval = interval
+		}
+	}
+}
+
+// WithProbabilisticExtension keeps hot entries alive without keeping every entry alive,
+// unlike resetting the TTL on every read. When Get hits an entry that expires in less than
+// threshold, it extends the expiration by extension with a probability proportional to the
+// entry's hit rate: the number of hits the entry would get within threshold at its average
+// rate since it was written, capped at 1. An entry read about once per threshold or more is
+// always extended, while a rarely read one is likely to expire and be reloaded. Entries with
+// a TTL of 0 never expire and are unaffected. Hits are counted per entry as with
+// WithAccessTracking.
+func WithProbabilisticExtension(threshold, extension time.Duration) Option {
+	return func(s *Storage) {
+		s.extendThreshold = threshold
+		s.extendExtension = extension
+	}
+}
+
+// WithIdleStoreTimeout is a dead man's switch for caches that should vanish when their
+// owner stalls: if no operation on entries, such as Get, Set, Delete, Increment or Toggle,
+// happens for d, a background watcher calls Reset, and also Close if closeOnIdle is set.
+// Every such operation restarts the timer; reading statistics does not. This applies to
+// storage as a whole and is unrelated to the expiration of individual keys. The watcher
+// checks for activity every quarter of d, so storage is reset between d and 1.25 d after
+// the last operation. Close stops the watcher.
+func WithIdleStoreTimeout(d time.Duration, closeOnIdle bool) Option {
+	return func(s *Storage) {
+		s.idleTimeout = d
+		s.closeOnIdle = closeOnIdle
+	}
+}
+
+// WithWriteCoalescing buffers Set calls on the keys coalesce reports true for, such as a
+// progress value updated hundreds of times per second, instead of taking the write lock
+// for each. Only the latest buffered value of a key is kept, and a background goroutine
+// stores the buffered values every interval under a single write lock. Until then, readers
+// see the previous value, so reads of coalesced keys may be up to interval stale. Set
+// returns validation errors as usual, while errors found when storing, such as
+// ErrImmutable, are logged and the write dropped. Delete and Reset discard the buffered
+// writes of the keys they remove. Close stores the buffered writes and stops the goroutine;
+// later writes are stored synchronously. coalesce must be fast and must not call back into
+// storage.
+func WithWriteCoalescing(interval time.Duration, coalesce func(key string) bool) Option {
+	return func(s *Storage) {
+		if interval > 0 && coalesce != nil {
+			s.coalesce = coalesce
+			s.coalesceEvery = interval
+		}
+	}
+}
+
+// WithMaxLifetime bounds how long a value can live after it was written, however often it
+// is accessed: the expiration of an entry never goes past its creation time, as reported by
+// CreatedAt, plus d. Touch, GetAndTouch and WithProbabilisticExtension extend entries up to
+// that limit only, and Touch with a TTL of 0, which otherwise makes an entry permanent,
+// makes it expire at the limit instead. Writing a new value, such as with Set, starts a new
+// lifetime. Unlike WithMaxTTL, which caps each TTL as it is set, this caps the total age of
+// an entry across TTL extensions. A value of 0, the default, sets no limit.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(s *Storage) {
+		s.maxLifetime = d
+	}
+}
+
+// WithEventCoalescing buffers the events sent to Events and EventsFiltered subscribers and
+// delivers them every interval, with repeats of the same event within an interval merged
+// into one, so a key set thousands of times per second yields one EventSet per interval.
+// Events are merged per key and event type: a key set and then deleted within an interval
+// yields both an EventSet and an EventDelete. Intermediate events are lost by design, and
+// the order of events within an interval is that of their first occurrence, so consumers
+// should read the current state from storage rather than infer it from the events. Close
+// delivers the buffered events and stops the goroutine; later events are delivered right away.
+func WithEventCoalescing(interval time.Duration) Option {
+	return func(s *Storage) {
+		s.eventCoalesceEvery = interval
+	}
+}
+
+// WithPolledEvents switches events from being pushed to channels to being pulled: instead
+// of being sent to the subscribers of Events and EventsFiltered, which then receive
+// nothing, events accumulate in a buffer that DrainEvents returns and empties, so
+// consumers can poll without a long-lived goroutine. The buffer holds at most bufferSize
+// events, and events arriving while it is full are dropped until the next drain, so it
+// must be drained often enough for the rate of changes. It combines with
+// WithEventCoalescing, which then merges events before they are buffered.
+func WithPolledEvents(bufferSize int) Option {
+	return func(s *Storage) {
+		s.polledEventsMax = bufferSize
+	}
+}
+
+// WithExpirationStrategy selects how the cleanup goroutine finds expired entries. The
+// default is StrategyScan. StrategyTimerWheel uses the expiration granularity as its bucket
+// width, or one second if none is set, and ignores WithCleanupTimeBudget, as its passes
+// only touch the entries that are due. PurgeExpired always checks every entry.
+func WithExpirationStrategy(strategy ExpirationStrategy) Option {
+	return func(s *Storage) {
+		if strategy == StrategyTimerWheel {
+			s.wheel = make(map[int64][]string)
+		} else {
+			s.wheel = nil
+		}
+	}
+}
+
+// WithTTLRules sets the TTL of entries set with DefaultTTL by key pattern, to centralize
+// lifetime policy by namespace, for example 30 minutes for "session:*" and 5 minutes for
+// "cache:*". Rules are tried in order and the first whose pattern matches the key wins, so
+// specific patterns must come before broader ones. Keys matching no rule fall through to
+// the function set by WithTTLFunc, or never expire without one. An explicit TTL passed to
+// Set always takes precedence over the rules.
+func WithTTLRules(rules []TTLRule) Option {
+	return func(s *Storage) {
+		s.ttlRules = append([]TTLRule(nil), rules...)
+	}
+}
+
+// WithTTLFunc sets the function computing the TTL of entries set with DefaultTTL, so the
+// lifetime can depend on the key or value, for example short for large blobs and long for
+// small configs. An explicit TTL passed to Set always takes precedence over fn.
+func WithTTLFunc(fn func(key string, value interface{}) time.Duration) Option {
+	return func(s *Storage) {
+		s.ttlFunc = fn
+	}
+}
+
+// WithSerializedValues makes storage keep values encoded with codec: Set encodes a value to
+// bytes right away and Get decodes it on every read. Holding byte slices instead of
+// pointer-rich values greatly reduces the work of the garbage collector on very large
+// stores, at the cost of CPU time on every read and write. Every stored value must be
+// supported by codec, and each Get returns a fresh copy of the value.
+func WithSerializedValues(codec Codec) Option {
+	return func(s *Storage) {
+		s.codec = codec
+	}
+}
+
+// WithRejectNonSerializable makes writes of functions, channels and unsafe pointers fail
+// with ErrNonSerializable. Such values cannot be encoded by a codec and are usually stored
+// by mistake, so this catches them when they are written rather than when they are
+// encoded or persisted. Only the kind of the value itself is checked: a struct or slice
+// holding a function is accepted. It is off by default, so any value can be stored.
+func WithRejectNonSerializable() Option {
+	return func(s *Storage) {
+		s.rejectNonSerializable = true
+	}
+}
+
+// WithSetInterceptor sets a function that validates or normalizes values as they are
+// written by Set, SetOnce, SetWithGeneration and SetWithDependency, and by Warm and
+// GetOrCompute, which store through them. If fn returns an error, the write fails with
+// that error and nothing is stored; otherwise the value it returns is stored in place of
+// the original. It runs on every such write before the value is encoded and its size is
+// checked, so it must be fast, and it must not call back into the storage.
+func WithSetInterceptor(fn func(key string, value interface{}) (interface{}, error)) Option {
+	return func(s *Storage) {
+		s.setInterceptor = fn
+	}
+}
+
+// WithMaxValueBytes makes writes reject values larger than n bytes with ErrValueTooLarge,
+// guarding the memory budget against a single huge entry. The size is exact for strings,
+// byte slices and values encoded by WithSerializedValues. For other types it is a
+// best-effort estimate that follows references only a few levels deep and ignores runtime
+// overhead such as map buckets. A value of 0 disables the check.
+func WithMaxValueBytes(n int64) Option {
+	return func(s *Storage) {
+		s.maxValueBytes = n
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// The persistence format written by SaveToFile starts with a header of persistMagic and
+// the format version as a big-endian uint16, followed by the entries, encoded with gob as
+// a []persistedEntry of the layout of that version.
+//
+// Version history:
+//
+//	1: initial layout, with the key, value, expiration, creation time and immutability.
+//
+// A change to the layout must bump persistVersion and keep decoding the previous versions
+// in decodeEntries, migrating them to the current layout.
+const (
+	persistMagic   = "REMO"
+	persistVersion = uint16(1)
+)
+
+// persistedEntry is an entry as written by SaveToFile.
+type persistedEntry struct {
+	Key        string
+	Value      interface{}
+	Expiration time.Time
+	CreatedAt  time.Time
+	Immutable  bool
+}
+
+// SaveToFile writes the live entries of storage to the file at path, which LoadFromFile
+// can read back. The entries are taken from a Snapshot, so writers are not held up while
+// the file is written, and the file is replaced atomically by writing to a temporary file
+// next to it first. Values are encoded with gob, so concrete types other than the basic
+// ones must be registered with gob.Register, as with GobCodec.
+func (s *Storage) SaveToFile(path string) error {
+	snap := s.Snapshot()
+	entries := make([]persistedEntry, 0, len(snap.data))
+	for key, item := range snap.data {
+		value, err := s.decode(item.value)
+		if err != nil {
+			return fmt.Errorf("saving key %q: %w", key, err)
+		}
+		entries = append(entries, persistedEntry{
+			Key:        key,
+			Value:      value,
+			Expiration: item.expiration,
+			CreatedAt:  item.createdAt,
+			Immutable:  item.immutable,
+		})
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = writeEntries(w, entries)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeEntries writes the header of the current format version and entries to w.
+func writeEntries(w io.Writer, entries []persistedEntry) error {
+	if _, err := io.WriteString(w, persistMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, persistVersion); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// LoadFromFile replaces the contents of storage with the entries in the file at path,
+// written by SaveToFile, as Restore does with a snapshot. Entries that have expired since
+// they were saved are skipped. Files written in older format versions are migrated as they
+// are read. Each entry is validated as by Set with its remaining TTL, so an empty key, a
+// value over WithMaxValueBytes or one rejected by WithSetInterceptor fails the load, and
+// expirations are capped by WithMaxTTL. It returns ErrBadFormat for a file that does not
+// start with the persistence header, and ErrUnsupportedVersion for one written in a
+// version this package does not know, such as by a newer release; storage is left
+// unchanged in all of these cases.
+func (s *Storage) LoadFromFile(path string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries, err := readEntries(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", path, err)
+	}
+	now := s.now()
+	data := make(map[string]*item, len(entries))
+	for _, entry := range entries {
+		var ttl time.Duration
+		if !entry.Expiration.IsZero() {
+			if ttl = entry.Expiration.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		// Entries are validated and capped as by Set, with their remaining TTL.
+		item, err := s.prepareItem(entry.Key, entry.Value, ttl)
+		if err != nil {
+			s.recycleItems(data)
+			return fmt.Errorf("loading %s: key %q: %w", path, entry.Key, err)
+		}
+		item.createdAt = entry.CreatedAt
+		item.immutable = entry.Immutable
+		data[entry.Key] = item
+	}
+	s.Restore(&Snapshot{data: data, store: s})
+	return nil
+}
+
+// readEntries checks the header read from r and decodes the entries that follow it.
+func readEntries(r io.Reader) ([]persistedEntry, error) {
+	magic := make([]byte, len(persistMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != persistMagic {
+		return nil, ErrBadFormat
+	}
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, ErrBadFormat
+	}
+	return decodeEntries(version, gob.NewDecoder(r))
+}
+
+// decodeEntries decodes entries written in the given format version, migrating them to
+// the current layout.
+func decodeEntries(version uint16, dec *gob.Decoder) ([]persistedEntry, error) {
+	switch version {
+	case 1:
+		var entries []persistedEntry
+		if err := dec.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("decoding version %d entries: %w", version, err)
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("%w: version %d, newest known is %d", ErrUnsupportedVersion, version, persistVersion)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test that a file saved in the current format version loads back every live entry.
+func TestStorage_SaveToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.remo")
+	store := New()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Set("key", "value", 0)
+	store.Set("expiringKey", 42, time.Hour)
+	store.SetOnce("immutableKey", []interface{}{"a", "b"}, 0)
+	store.Set("expiredKey", "value", time.Second)
+	now = now.Add(2 * time.Second)
+
+	if err := store.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() failed: %v", err)
+	}
+
+	loaded := New(WithSerializedValues(GobCodec{}))
+	loaded.now = store.now
+	loaded.Set("staleKey", "value", 0)
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() failed: %v", err)
+	}
+	if loaded.Len() != 3 {
+		t.Errorf("Expected 3 entries, but got %d", loaded.Len())
+	}
+	if value, err := loaded.Get("key"); err != nil || value != "value" {
+		t.Errorf("Expected value, but got %v, %v", value, err)
+	}
+	if ttl, err := loaded.TTL("expiringKey"); err != nil || ttl != time.Hour-2*time.Second {
+		t.Errorf("Expected the saved expiration, but got %v, %v", ttl, err)
+	}
+	if err := loaded.Set("immutableKey", "other", 0); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected %v, but got %v", ErrImmutable, err)
+	}
+	if _, err := loaded.Get("staleKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected loading to replace the contents, but got %v", err)
+	}
+}
+
+// Test that files with an unknown version or without the header are rejected with a clear
+// error, leaving storage unchanged.
+func TestStorage_LoadFromFileRejected(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store.remo")
+	source := New()
+	source.Set("key", "value", 0)
+	if err := source.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(persistMagic)] = 0xff
+	tamperedPath := filepath.Join(dir, "tampered.remo")
+	os.WriteFile(tamperedPath, tampered, 0o600)
+
+	garbagePath := filepath.Join(dir, "garbage.remo")
+	os.WriteFile(garbagePath, []byte("key=value\n"), 0o600)
+
+	store := New()
+	store.Set("kept", "value", 0)
+	err = store.LoadFromFile(tamperedPath)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected %v, but got %v", ErrUnsupportedVersion, err)
+	}
+	if err := store.LoadFromFile(garbagePath); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("Expected %v, but got %v", ErrBadFormat, err)
+	}
+	if value, err := store.Get("kept"); err != nil || value != "value" {
+		t.Errorf("Expected storage to be unchanged, but got %v, %v", value, err)
+	}
+}
+
+// Test that loaded entries are validated and capped as by Set.
+func TestStorage_LoadFromFileValidated(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, entries []persistedEntry) string {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if err := writeEntries(file, entries); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	emptyKey := write("empty.remo", []persistedEntry{{Key: "", Value: "value"}})
+	oversized := write("oversized.remo", []persistedEntry{{Key: "key", Value: string(make([]byte, 1024))}})
+	permanent := write("permanent.remo", []persistedEntry{{Key: "key", Value: "value"}})
+
+	store := New(WithMaxValueBytes(256), WithMaxTTL(time.Hour))
+	store.Set("kept", "value", 0)
+	if err := store.LoadFromFile(emptyKey); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected %v, but got %v", ErrEmptyKey, err)
+	}
+	if err := store.LoadFromFile(oversized); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected %v, but got %v", ErrValueTooLarge, err)
+	}
+	if value, err := store.Get("kept"); err != nil || value != "value" {
+		t.Errorf("Expected storage to be unchanged, but got %v, %v", value, err)
+	}
+
+	// Test a permanent entry being capped by WithMaxTTL.
+	if err := store.LoadFromFile(permanent); err != nil {
+		t.Fatalf("LoadFromFile() failed: %v", err)
+	}
+	if ttl, err := store.TTL("key"); err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected a TTL capped at 1h, got %v, %v", ttl, err)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"sync"
+	"time"
+)
+
+// allocItem returns an item holding value and expiring at expiration, taken from the pool
+// set up by WithItemPool if there is one.
+func (s *Storage) allocItem(value interface{}, expiration time.Time) *item {
+	if s.itemPool == nil {
+		return newItem(value, expiration)
+	}
+	item := s.itemPool.Get().(*item)
+	item.expiration = expiration
+	item.value = value
+	return item
+}
+
+// recycleItem returns item to the pool set up by WithItemPool, if there is one. Only items
+// that were never stored may be recycled: once stored, an item may still be read by a
+// reader that looked it up before it was removed. The item is fully reset first, so no
+// state of its previous use leaks into the next one and its value can be collected.
+func (s *Storage) recycleItem(it *item) {
+	if s.itemPool == nil {
+		return
+	}
+	*it = item{}
+	s.itemPool.Put(it)
+}
+
+// newItemPool returns a pool of empty items.
+func newItemPool() *sync.Pool {
+	return &sync.Pool{New: func() interface{} { return new(item) }}
+}
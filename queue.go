@@ -0,0 +1,136 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Queue is a FIFO queue of values kept in a Storage, for simple producer/consumer handoff.
+// Each value is stored under its own key, made of the queue's prefix and a monotonic
+// sequence number.
+//
+// Delivery is at-most-once: Pop removes a value from storage before returning it, so a
+// value is lost if the consumer fails after popping it. Values deleted from storage
+// before being popped, for example by Reset, are skipped.
+type Queue struct {
+	store  *Storage
+	prefix string
+
+	mu   sync.Mutex
+	head uint64
+	// tail is the next sequence number to reserve and published the one after the last of
+	// the consecutive pushes that have finished, so Pop never passes a push still storing its
+	// value. finished holds the sequence numbers past published whose push has finished.
+	tail      uint64
+	published uint64
+	finished  map[uint64]struct{}
+	ready     chan struct{}
+}
+
+// NewQueue creates an empty queue storing its values in store under keys starting with prefix.
+// Queues sharing a store must use distinct prefixes.
+func NewQueue(store *Storage, prefix string) *Queue {
+	return &Queue{
+		store:    store,
+		prefix:   prefix,
+		finished: make(map[uint64]struct{}),
+		ready:    make(chan struct{}),
+	}
+}
+
+// Push appends value to the queue and returns the key it is stored under. The value is
+// stored without holding the queue lock, so a Set waiting for room with PolicyBlock does not
+// keep Pop from making it.
+func (q *Queue) Push(value interface{}) (string, error) {
+	q.mu.Lock()
+	seq := q.tail
+	q.tail++
+	q.mu.Unlock()
+
+	id := q.key(seq)
+	err := q.store.Set(id, value, 0)
+	q.finish(seq)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// finish records the push of seq as finished, whether it stored its value or not, and
+// publishes the values up to the first push still running. A failed push leaves no value
+// under its key, so Pop skips it.
+func (q *Queue) finish(seq uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finished[seq] = struct{}{}
+	published := q.published
+	for {
+		if _, ok := q.finished[q.published]; !ok {
+			break
+		}
+		delete(q.finished, q.published)
+		q.published++
+	}
+	if q.published == published {
+		return
+	}
+
+	// Wake every waiting Pop.
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+// Pop removes and returns the oldest value in the queue, blocking until one is available
+// or ctx is done. If the oldest value cannot be taken, for example because it fails to
+// decode or the storage lock times out, Pop returns the error and leaves the value at the
+// head of the queue, so the next Pop retries it.
+func (q *Queue) Pop(ctx context.Context) (interface{}, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		q.mu.Lock()
+		if q.head == q.published {
+			ready := q.ready
+			q.mu.Unlock()
+
+			select {
+			case <-ready:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		// Take the value under the queue lock, so a failed take can leave it at the head.
+		value, err := q.store.take(q.key(q.head))
+		skip := errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired)
+		if err == nil || skip {
+			q.head++
+		}
+		q.mu.Unlock()
+		if !skip {
+			return value, err
+		}
+	}
+}
+
+// key returns the storage key of the value with the given sequence number.
+func (q *Queue) key(seq uint64) string {
+	return fmt.Sprintf("%s%020d", q.prefix, seq)
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueue_FIFO(t *testing.T) {
+	store := New()
+	queue := NewQueue(store, "jobs:")
+
+	for i := 0; i < 10; i++ {
+		if _, err := queue.Push(i); err != nil {
+			t.Fatalf("Push() failed: %v", err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		value, err := queue.Pop(context.Background())
+		if err != nil {
+			t.Fatalf("Pop() failed: %v", err)
+		}
+		if value != i {
+			t.Errorf("Expected %d, but got %v", i, value)
+		}
+	}
+
+	// Test popped values being removed from storage.
+	if len(store.data) != 0 {
+		t.Errorf("Expected an empty storage, but got %d entries", len(store.data))
+	}
+}
+
+func TestQueue_BlockingPop(t *testing.T) {
+	queue := NewQueue(New(), "jobs:")
+
+	result := make(chan interface{})
+	go func() {
+		value, err := queue.Pop(context.Background())
+		if err != nil {
+			t.Errorf("Pop() failed: %v", err)
+		}
+		result <- value
+	}()
+
+	// Test Pop blocking while the queue is empty.
+	select {
+	case value := <-result:
+		t.Fatalf("Expected Pop to block, but got %v", value)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	queue.Push("job")
+	select {
+	case value := <-result:
+		if value != "job" {
+			t.Errorf("Expected job, but got %v", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Pop to unblock after Push")
+	}
+
+	// Test Pop giving up when its context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := queue.Pop(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestQueue_SkipsDeleted(t *testing.T) {
+	store := New()
+	queue := NewQueue(store, "jobs:")
+
+	id, _ := queue.Push("first")
+	queue.Push("second")
+	store.Delete(id)
+
+	value, err := queue.Pop(context.Background())
+	if err != nil || value != "second" {
+		t.Errorf("Expected second, got %v, %v", value, err)
+	}
+}
+
+// failingCodec is a GobCodec whose Decode fails while fail is set.
+type failingCodec struct {
+	GobCodec
+	fail bool
+}
+
+var errDecode = errors.New("decode failed")
+
+func (c *failingCodec) Decode(data []byte) (interface{}, error) {
+	if c.fail {
+		return nil, errDecode
+	}
+	return c.GobCodec.Decode(data)
+}
+
+// Test that a value failing to decode is reported by Pop and stays at the head for a retry.
+func TestQueue_PopError(t *testing.T) {
+	codec := &failingCodec{}
+	store := New(WithSerializedValues(codec))
+	queue := NewQueue(store, "jobs:")
+	queue.Push("first")
+	queue.Push("second")
+
+	codec.fail = true
+	if _, err := queue.Pop(context.Background()); !errors.Is(err, errDecode) {
+		t.Errorf("Expected %v, but got %v", errDecode, err)
+	}
+	if _, err := queue.Pop(context.Background()); !errors.Is(err, errDecode) {
+		t.Errorf("Expected %v again, but got %v", errDecode, err)
+	}
+
+	// Test the retry returning the value that failed, in order.
+	codec.fail = false
+	for _, expected := range []string{"first", "second"} {
+		value, err := queue.Pop(context.Background())
+		if err != nil || value != expected {
+			t.Errorf("Expected %s, got %v, %v", expected, value, err)
+		}
+	}
+}
+
+// Test that a Push waiting for room with PolicyBlock does not keep Pop from making room.
+func TestQueue_PushBlockedOnFullStorage(t *testing.T) {
+	store := New(WithMaxEntries(1), WithFullPolicy(PolicyBlock))
+	queue := NewQueue(store, "jobs:")
+	queue.Push("first")
+
+	pushed := make(chan error, 1)
+	go func() {
+		_, err := queue.Push("second")
+		pushed <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, expected := range []string{"first", "second"} {
+		value, err := queue.Pop(ctx)
+		if err != nil || value != expected {
+			t.Fatalf("Expected %s, got %v, %v", expected, value, err)
+		}
+	}
+	if err := <-pushed; err != nil {
+		t.Errorf("Expected the blocked Push to succeed, but got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimit applies a sliding-window rate limit of limit requests per window to key. It
+// drops the request timestamps stored under key that are older than window and, if fewer
+// than limit remain, records a request now. It reports whether the request is allowed and
+// how many more requests the window allows. A denied request leaves the stored timestamps
+// untouched, so it publishes no event. This happens under the write lock, so concurrent
+// callers never exceed the limit. The timestamps are stored as a []time.Time that expires
+// when the newest of them leaves the window, so idle keys expire. It returns
+// ErrInvalidRateLimit if window or limit is not positive, and ErrWrongType if key holds a
+// value that is not a rate window.
+func (s *Storage) RateLimit(key string, window time.Duration, limit int) (allowed bool, remaining int, err error) {
+	defer wrapOpError("RateLimit", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return false, 0, err
+	}
+	if key == "" {
+		return false, 0, ErrEmptyKey
+	}
+	if window <= 0 || limit <= 0 {
+		return false, 0, ErrInvalidRateLimit
+	}
+
+	if err := s.lock(); err != nil {
+		return false, 0, err
+	}
+	defer s.mu.Unlock()
+	if err := s.makeRoom(context.Background(), key); err != nil {
+		return false, 0, err
+	}
+	if err := s.checkWritable(key); err != nil {
+		return false, 0, err
+	}
+
+	now := s.now()
+	var timestamps []time.Time
+	if item, exists := s.data[key]; exists && !item.isExpiredAt(now) {
+		current, err := s.decode(item.value)
+		if err != nil {
+			return false, 0, err
+		}
+		stored, ok := current.([]time.Time)
+		if !ok {
+			return false, 0, ErrWrongType
+		}
+		timestamps = stored
+	}
+
+	// Copy rather than prune in place, so windows returned earlier never change.
+	start := 0
+	for start < len(timestamps) && !timestamps[start].After(now.Add(-window)) {
+		start++
+	}
+	if len(timestamps)-start >= limit {
+		// Denied requests are not recorded, so the stored window stays as it is.
+		return false, 0, nil
+	}
+	recent := make([]time.Time, len(timestamps)-start, len(timestamps)-start+1)
+	copy(recent, timestamps[start:])
+	recent = append(recent, now)
+
+	stored, err := s.encode(recent)
+	if err != nil {
+		return false, 0, err
+	}
+	ttl := window - now.Sub(recent[len(recent)-1])
+	s.storeItem(key, newItem(stored, s.calculateExpiration(ttl)))
+	return true, limit - len(recent), nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorage_RateLimit(t *testing.T) {
+	store := New()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	// Test requests under the limit being allowed.
+	for i := 0; i < 3; i++ {
+		allowed, remaining, err := store.RateLimit("client", time.Minute, 3)
+		if err != nil || !allowed || remaining != 2-i {
+			t.Errorf("Expected request %d to be allowed with %d remaining, got %v, %d, %v", i, 2-i, allowed, remaining, err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	// Test requests over the limit being denied without writing the window.
+	events, unsubscribe := store.Events()
+	version := store.data["client"].version
+	if allowed, remaining, err := store.RateLimit("client", time.Minute, 3); err != nil || allowed || remaining != 0 {
+		t.Errorf("Expected the request to be denied, got %v, %d, %v", allowed, remaining, err)
+	}
+	unsubscribe()
+	if len(events) != 0 || store.data["client"].version != version {
+		t.Errorf("Expected a denied request to leave the window untouched")
+	}
+
+	// Test the window sliding past the oldest request.
+	now = now.Add(31 * time.Second)
+	if allowed, remaining, err := store.RateLimit("client", time.Minute, 3); err != nil || !allowed || remaining != 0 {
+		t.Errorf("Expected the request to be allowed once the oldest left the window, got %v, %d, %v", allowed, remaining, err)
+	}
+
+	// Test the key expiring once idle for a window.
+	now = now.Add(time.Minute + time.Second)
+	if _, err := store.Get("client"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected the idle window to expire, but got %v", err)
+	}
+
+	// Test invalid arguments and values of another type.
+	if _, _, err := store.RateLimit("client", 0, 3); !errors.Is(err, ErrInvalidRateLimit) {
+		t.Errorf("Expected ErrInvalidRateLimit, but got %v", err)
+	}
+	store.Set("stringKey", "value", 0)
+	if _, _, err := store.RateLimit("stringKey", time.Minute, 3); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, but got %v", err)
+	}
+}
+
+func TestStorage_RateLimitSerialized(t *testing.T) {
+	store := New(WithSerializedValues(GobCodec{}))
+
+	// Test rate windows surviving encoding.
+	for i := 0; i < 2; i++ {
+		if allowed, _, err := store.RateLimit("client", time.Minute, 2); err != nil || !allowed {
+			t.Errorf("Expected request %d to be allowed, got %v, %v", i, allowed, err)
+		}
+	}
+	if allowed, _, err := store.RateLimit("client", time.Minute, 2); err != nil || allowed {
+		t.Errorf("Expected the request to be denied, got %v, %v", allowed, err)
+	}
+}
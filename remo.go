@@ -17,133 +17,905 @@ package remo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// cleanupClockStride is how many keys a time-budgeted cleanup pass checks between clock reads.
+const cleanupClockStride = 64
+
 var (
-	ErrKeyNotFound = errors.New("key not found")
-	ErrKeyExpired  = errors.New("key has expired")
-	ErrEmptyKey    = errors.New("key cannot be empty")
-	ErrNegativeTTL = errors.New("TTL cannot be negative")
+	ErrKeyNotFound        = errors.New("key not found")
+	ErrKeyExpired         = errors.New("key has expired")
+	ErrEmptyKey           = errors.New("key cannot be empty")
+	ErrNegativeTTL        = errors.New("TTL cannot be negative")
+	ErrNotComparable      = errors.New("value is not comparable")
+	ErrWrongType          = errors.New("value has the wrong type")
+	ErrInvalidMaxLen      = errors.New("max length must be positive")
+	ErrImmutable          = errors.New("key is immutable")
+	ErrValueTooLarge      = errors.New("value is too large")
+	ErrDependencyCycle    = errors.New("dependency cycle")
+	ErrNotAnInteger       = errors.New("value is not an integer")
+	ErrStoreFull          = errors.New("storage is full")
+	ErrInvalidRateLimit   = errors.New("rate limit window and limit must be positive")
+	ErrNonSerializable    = errors.New("value cannot be serialized")
+	ErrMalformedLine      = errors.New("line is not a key=value pair")
+	ErrClosed             = errors.New("storage is closed")
+	ErrWriteRateExceeded  = errors.New("write rate of key exceeded")
+	ErrBadFormat          = errors.New("not a remo persistence file")
+	ErrUnsupportedVersion = errors.New("unsupported persistence format version")
 )
 
+// lastStorageID is the ID of the most recently created storage.
+var lastStorageID uint64
+
 // Storage represents an in-memory key-value storage with expiration.
+// Keys are arbitrary non-empty byte strings: they may contain NULs and need not be valid UTF-8.
 type Storage struct {
+	hits            uint64 // accessed atomically; first for 64-bit alignment
+	misses          uint64 // accessed atomically
+	cleanupOverruns uint64 // accessed atomically
+	skippedCleanups uint64 // accessed atomically
+	evictions       uint64 // accessed atomically
+	expirations     uint64 // accessed atomically
+
+	refreshAheadServed    uint64 // accessed atomically
+	refreshAheadTriggered uint64 // accessed atomically
+	length                int64  // accessed atomically; entries in data, for ApproxLen
+	lastActive            int64  // unix nanoseconds of the last operation, accessed atomically
+	closing               int32  // set once Close starts, accessed atomically
+	closed                int32  // set once Close completes, accessed atomically
+
+	id             uint64
 	mu             sync.RWMutex
 	data           map[string]*item
+	cleanupMu      sync.Mutex // guards cleanupRunning, cancel and cleanupDone
 	cleanupRunning bool
-	ctx            context.Context
 	cancel         context.CancelFunc
+	cleanupDone    chan struct{}
+
+	markers        map[string]*item
+	existsLoader   func(key string) (bool, error)
+	existsTTL      time.Duration
+	loadSem        chan struct{}
+	loader         func(ctx context.Context, key string) (interface{}, error)
+	staleWindow    time.Duration
+	refreshAhead   time.Duration
+	refreshing     map[string]struct{}
+	loadErrors     map[string]loadFailure
+	loadAttempts   int
+	loadBackoff    time.Duration
+	trackAccess    bool
+	lazyOnly       bool
+	skipUnchanged  bool
+	copyContainers bool
+	equality       func(a, b interface{}) bool
+
+	cleanupBudget time.Duration
+	autoCleanup   time.Duration
+	batchChunk    int
+	startJitter   time.Duration
+	randInt63n    func(n int64) int64
+	cursorMu      sync.Mutex
+	cleanupCursor []string
+
+	cleanupPolicies []cleanupPolicy // sorted by interval once storage is created
+	policiesStop    chan struct{}
+	policiesDone    chan struct{}
+
+	peakLen int
+
+	nonBlockingLoad bool
+	loading         map[string]struct{} // keys loaded by Get with nonBlockingLoad
+
+	ttlFunc  func(key string, value interface{}) time.Duration
+	ttlRules []TTLRule
+	codec    Codec
+
+	generation  uint64
+	lastVersion uint64 // last token given to a stored value
+
+	now    func() time.Time
+	logger Logger
+
+	tenantFunc func(ctx context.Context) string
+
+	dependencies map[string][]string
+	dependents   map[string]map[string]struct{}
+
+	maxValueBytes int64
+
+	interned map[string]string
+
+	setInterceptor func(key string, value interface{}) (interface{}, error)
+
+	granularity time.Duration
+	maxTTL      time.Duration
+	maxLifetime time.Duration
+
+	rejectNonSerializable bool
+	lockTimeout           time.Duration
+
+	extendThreshold time.Duration
+	extendExtension time.Duration
+	wheel           map[int64][]string
+	wheelTick       int64
+
+	bloom *bloomFilter
+
+	keyLocksMu sync.Mutex
+	keyLocks   map[string]*keyLock
+
+	maxEntries int
+	fullPolicy FullPolicy
+	spaceFreed *sync.Cond // set with PolicyBlock, signaled when entries are removed
+
+	onExpire func(expired []KeyValue)
+	onEvict  func(key string, value interface{})
+
+	itemPool *sync.Pool
+
+	writeRateLimit  int
+	writeRateWindow time.Duration
+	dropRateLimited bool
+	writeBuckets    map[string]*writeBucket
+
+	tombstoneTTL time.Duration
+	tombstones   []Tombstone // oldest first
+
+	eventsMu          sync.RWMutex // guards subscribers and subscribersClosed
+	subscribers       []*subscriber
+	subscribersClosed bool
+
+	polledEventsMax int
+	polledEventsMu  sync.Mutex // guards polledEvents
+	polledEvents    []Event
+
+	eventCoalesceEvery time.Duration
+	pendingEventsMu    sync.Mutex // guards pendingEvents, bufferedEvents and eventsClosed
+	pendingEvents      []Event
+	bufferedEvents     map[Event]struct{}
+	eventsClosed       bool
+	eventsStop         chan struct{}
+	eventsDone         chan struct{}
+
+	deleteQueue    chan queuedDelete
+	deleteInterval time.Duration
+	deleteMu       sync.RWMutex // guards deleteClosed
+	deleteClosed   bool
+	deleteStop     chan struct{}
+	deleteDone     chan struct{}
+
+	idleTimeout  time.Duration
+	closeOnIdle  bool
+	idleStop     chan struct{}
+	idleStopOnce sync.Once
+	idleDone     chan struct{}
+
+	coalesce       func(key string) bool
+	coalesceEvery  time.Duration
+	coalesceMu     sync.Mutex // guards pendingWrites and coalesceClosed
+	pendingWrites  map[string]*item
+	coalesceClosed bool
+	coalesceStop   chan struct{}
+	coalesceDone   chan struct{}
 }
 
 // item represents a key-value pair with an expiration time.
 type item struct {
+	hits       uint64 // accessed atomically; first for 64-bit alignment
+	lastAccess int64  // unix nanoseconds, accessed atomically
+	lastUsed   int64  // unix nanoseconds of the last read or write, accessed atomically
 	expiration time.Time
+	createdAt  time.Time // when the value was written; zero until the item is stored
+	version    uint64    // token of the value for GetToken, set when the item is stored
 	value      interface{}
+	immutable  bool
 }
 
-// New creates and returns a new instance of Storage.
-func New() *Storage {
+// New creates and returns a new instance of Storage configured with the given options.
+func New(opts ...Option) *Storage {
 	store := &Storage{
+		id:             atomic.AddUint64(&lastStorageID, 1),
 		data:           make(map[string]*item),
 		cleanupRunning: false,
+		markers:        make(map[string]*item),
+		dependencies:   make(map[string][]string),
+		dependents:     make(map[string]map[string]struct{}),
+		now:            time.Now,
+		randInt63n:     rand.Int63n,
+		logger:         log.Default(),
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	if store.wheel != nil && store.granularity <= 0 {
+		store.granularity = defaultWheelGranularity
+	}
+	if store.maxEntries > 0 && store.fullPolicy == PolicyBlock {
+		store.spaceFreed = sync.NewCond(&store.mu)
+	}
+	if store.deleteQueue != nil {
+		store.startAsyncDelete(store.deleteInterval)
+	}
+	if store.eventCoalesceEvery > 0 {
+		store.startEventCoalescing(store.eventCoalesceEvery)
+	}
+	if store.coalesce != nil {
+		store.startCoalescing(store.coalesceEvery)
+	}
+	if store.idleTimeout > 0 {
+		store.startIdleWatch()
+	}
+	if len(store.cleanupPolicies) > 0 && !store.lazyOnly {
+		store.startCleanupPolicies()
+	}
+	if store.autoCleanup > 0 {
+		store.StartCleanup(store.autoCleanup)
 	}
 	return store
 }
 
 // Get retrieves a value from storage by key. Returns nil if the key does not exist or has expired.
-func (s *Storage) Get(key string) (interface{}, error) {
+// An expired entry is removed as it is read. With WithLoader, a miss loads the value from
+// the origin, and with WithStaleWindow, a recently expired value is served while it is
+// refreshed.
+func (s *Storage) Get(key string) (value interface{}, err error) {
+	defer wrapOpError("Get", key, &err)
+	value, _, err = s.getDetailed(context.Background(), key)
+	return value, err
+}
+
+// GetInto is a fast path for Get in read-heavy loops. On a hit it stores the value in dst
+// and returns true; otherwise it returns false and leaves dst unchanged. It reports no error,
+// so a miss, an expired entry and a value that fails to decode all return false, and it does
+// not consult the loader or serve stale entries. With these out of the way, a hit does not
+// allocate unless a codec or WithContainerCopy has to build the value.
+func (s *Storage) GetInto(key string, dst *interface{}) bool {
+	if s.isClosed() {
+		return false
+	}
+	s.markActive()
 	s.mu.RLock()
-	item, exists := s.data[key]
+	item, exists := s.lookup(key)
 	s.mu.RUnlock()
 
-	if !exists {
-		return nil, ErrKeyNotFound
+	now := s.now()
+	if !exists || item.isExpiredAt(now) {
+		atomic.AddUint64(&s.misses, 1)
+		if exists && s.isReclaimable(item, now) {
+			s.removeExpiredItem(key, item)
+		}
+		return false
 	}
-
-	if item.isExpired() {
-		return nil, ErrKeyExpired
+	value, err := s.hit(item, now)
+	if err != nil {
+		return false
 	}
+	s.maybeExtend(key, item, now)
+	*dst = value
+	return true
+}
 
-	return item.value, nil
+// MustGet is like Get, but panics with the key and the error if there is no live value.
+// It is meant for initialization, such as reading required configuration at startup, where
+// a missing key is a programming error; request paths should use Get and handle the error.
+func (s *Storage) MustGet(key string) interface{} {
+	value, err := s.Get(key)
+	if err != nil {
+		panic(fmt.Sprintf("remo: MustGet(%q): %v", key, err))
+	}
+	return value
 }
 
 // Set sets a key-value pair in storage with an optional time-to-live (TTL) duration.
-func (s *Storage) Set(key string, value interface{}, ttl time.Duration) error {
-	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+func (s *Storage) Set(key string, value interface{}, ttl time.Duration) (err error) {
+	defer wrapOpError("Set", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if s.coalesce != nil && s.coalesce(key) {
+		item, err := s.prepareItem(key, value, ttl)
+		if err != nil {
+			return err
+		}
+		if s.coalesceWrite(key, item) {
+			s.markActive()
+			return nil
+		}
+		_, err = s.setItem(context.Background(), key, item)
 		return err
 	}
+	_, err = s.set(context.Background(), key, value, ttl, false)
+	return err
+}
 
-	expiration := s.calculateExpiration(ttl)
-	s.mu.Lock()
-	s.data[key] = newItem(value, expiration)
-	s.mu.Unlock()
-	return nil
+// SetWithGeneration sets a key-value pair like Set and returns the generation it was written
+// into. A concurrent Reset can discard a write right after Set returns; comparing the returned
+// generation with Generation tells a write that was reset apart from one that is still stored.
+func (s *Storage) SetWithGeneration(key string, value interface{}, ttl time.Duration) (generation uint64, err error) {
+	defer wrapOpError("SetWithGeneration", key, &err)
+	return s.set(context.Background(), key, value, ttl, false)
+}
+
+// Generation returns the current generation of storage, which Reset and Restore advance.
+func (s *Storage) Generation() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.generation
+}
+
+// SetOnce sets a key-value pair like Set and makes the key immutable: until it is deleted or
+// expires, Set, CompareAndSwap and every other write to the key return ErrImmutable.
+func (s *Storage) SetOnce(key string, value interface{}, ttl time.Duration) (err error) {
+	defer wrapOpError("SetOnce", key, &err)
+	_, err = s.set(context.Background(), key, value, ttl, true)
+	return err
+}
+
+// set stores a key-value pair and returns the generation it was written into. ctx bounds
+// the wait for room under PolicyBlock.
+func (s *Storage) set(ctx context.Context, key string, value interface{}, ttl time.Duration, immutable bool) (uint64, error) {
+	if err := s.checkOpen(); err != nil {
+		return 0, err
+	}
+	item, err := s.prepareItem(key, value, ttl)
+	if err != nil {
+		return 0, err
+	}
+	item.immutable = immutable
+	return s.setItem(ctx, key, item)
+}
+
+// setItem stores an item prepared by prepareItem and returns the generation it was written
+// into, like set.
+func (s *Storage) setItem(ctx context.Context, key string, item *item) (uint64, error) {
+	s.markActive()
+	if err := s.lock(); err != nil {
+		return 0, err
+	}
+	defer s.mu.Unlock()
+	if err := s.makeRoom(ctx, key); err != nil {
+		s.recycleItem(item)
+		return 0, err
+	}
+	if err := s.checkWritable(key); err != nil {
+		s.recycleItem(item)
+		return 0, err
+	}
+	if !s.allowWrite(key) {
+		s.recycleItem(item)
+		if s.dropRateLimited {
+			return s.generation, nil
+		}
+		return 0, ErrWriteRateExceeded
+	}
+	if s.skipUnchanged && s.isUnchanged(key, item) {
+		s.recycleItem(item)
+		return s.generation, nil
+	}
+	s.storeItem(key, item)
+	return s.generation, nil
 }
 
-// Delete removes an item from storage.
+// isUnchanged reports whether key already holds a live item with the same value,
+// expiration and immutability as item. Values that cannot be compared count as changed.
+// The caller must hold the lock.
+func (s *Storage) isUnchanged(key string, item *item) bool {
+	current, exists := s.data[key]
+	if !exists || current.isExpiredAt(s.now()) || current.immutable != item.immutable || !current.expiration.Equal(item.expiration) {
+		return false
+	}
+	currentValue, err := s.decode(current.value)
+	if err != nil {
+		return false
+	}
+	value, err := s.decode(item.value)
+	if err != nil {
+		return false
+	}
+	equal, err := s.equal(currentValue, value)
+	return err == nil && equal
+}
+
+// prepareItem validates a key-value pair and turns it into an item ready to be stored.
+func (s *Storage) prepareItem(key string, value interface{}, ttl time.Duration) (*item, error) {
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return nil, err
+	}
+	if s.setInterceptor != nil {
+		intercepted, err := s.setInterceptor(key, value)
+		if err != nil {
+			return nil, err
+		}
+		value = intercepted
+	}
+	ttl, err := s.resolveTTL(key, value, ttl)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.encode(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkValueSize(stored); err != nil {
+		return nil, err
+	}
+	return s.allocItem(stored, s.calculateExpiration(ttl)), nil
+}
+
+// Has reports whether a live value is stored under key. On a miss it consults the
+// exists loader, if one is configured, and caches its answer.
+func (s *Storage) Has(key string) (found bool, err error) {
+	defer wrapOpError("Has", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	if err := s.rlock(); err != nil {
+		return false, err
+	}
+	item, exists := s.lookup(key)
+	if exists && !item.isExpiredAt(s.now()) {
+		s.mu.RUnlock()
+		return true, nil
+	}
+	marker, marked := s.markers[key]
+	s.mu.RUnlock()
+
+	if marked && !marker.isExpiredAt(s.now()) {
+		return marker.value.(bool), nil
+	}
+	if s.existsLoader == nil {
+		return false, nil
+	}
+	return s.loadExists(key)
+}
+
+// Delete removes an item from storage. With WithAsyncDelete, the deletion is queued and
+// applied later.
 func (s *Storage) Delete(key string) {
+	if s.isClosed() {
+		return
+	}
+	s.markActive()
+	if s.enqueueDelete(key) {
+		return
+	}
 	s.mu.Lock()
+	s.dropPendingWrite(key)
 	_, exists := s.data[key]
 	if exists {
-		delete(s.data, key)
+		s.removeItem(key)
 	}
+	delete(s.markers, key)
 	s.mu.Unlock()
 }
 
-// Reset clears all keys from storage.
+// take removes the item stored under key and returns its value if it was live. An item
+// whose value fails to decode is kept, so it can be taken again.
+func (s *Storage) take(key string) (interface{}, error) {
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	s.dropPendingWrite(key)
+	item, exists := s.data[key]
+	if !exists {
+		return nil, keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		s.removeItem(key)
+		return nil, keyExpired(key)
+	}
+	value, err := s.decode(item.value)
+	if err != nil {
+		return nil, err
+	}
+	s.removeItem(key)
+	return value, nil
+}
+
+// Reset clears all keys from storage, zeros the counters reported by Stats and starts a
+// new generation. Use SoftReset to keep the counters.
 func (s *Storage) Reset() {
+	if s.isClosed() {
+		return
+	}
+	s.SoftReset()
+	s.resetStats()
+}
+
+// SoftReset clears all keys from storage and starts a new generation like Reset, but keeps
+// the counters reported by Stats, so hit ratios and other metrics derived from them carry
+// on across the flush instead of dropping to zero.
+//
+// Both pass every cleared entry to the evict callback set with WithEvictCallback, in the
+// background once storage is already empty.
+func (s *Storage) SoftReset() {
+	if s.isClosed() {
+		return
+	}
 	s.mu.Lock()
+	s.dropPendingWrites()
+	s.notifyEvictedAll(s.data)
 	s.data = make(map[string]*item)
+	atomic.StoreInt64(&s.length, 0)
+	s.markers = make(map[string]*item)
+	s.dependencies = make(map[string][]string)
+	s.dependents = make(map[string]map[string]struct{})
+	s.loadErrors = nil
+	s.writeBuckets = nil
+	s.resetInterned(s.data)
+	s.resetWheel(s.data)
+	s.resetBloom(s.data)
+	if s.spaceFreed != nil {
+		s.spaceFreed.Broadcast()
+	}
+	s.peakLen = 0
+	s.generation++
 	s.mu.Unlock()
 }
 
-// cleanup periodically removes expired items from storage.
-func (s *Storage) cleanup(interval time.Duration) {
+// cleanup periodically removes expired items from storage until ctx is done.
+// A pass that overruns the interval is followed by skipped ticks, so an oversized store
+// does not starve readers and writers of the lock by cleaning up back to back.
+func (s *Storage) cleanup(ctx context.Context, interval time.Duration) {
+	if s.startJitter > 0 {
+		timer := time.NewTimer(time.Duration(s.randInt63n(int64(s.startJitter))))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	skip := 0
 	for {
 		select {
 		case <-ticker.C:
-			s.removeExpiredItems()
-		case <-s.ctx.Done():
+			if skip > 0 {
+				skip--
+				atomic.AddUint64(&s.skippedCleanups, 1)
+				continue
+			}
+			skip = s.runTimedCleanupPass(interval)
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// StartCleanup starts the automatic cleanup goroutine.
+// runTimedCleanupPass runs a cleanup pass and returns how many ticks to skip because it overran the interval.
+func (s *Storage) runTimedCleanupPass(interval time.Duration) int {
+	start := s.now()
+	s.runCleanupPass()
+	elapsed := s.now().Sub(start)
+	if elapsed <= interval {
+		return 0
+	}
+
+	atomic.AddUint64(&s.cleanupOverruns, 1)
+	skip := int(elapsed / interval)
+	s.logger.Printf("Remo: [Cleanup] pass took %v, longer than the %v interval; skipping %d tick(s)", elapsed, interval, skip)
+	return skip
+}
+
+// StartCleanup starts the automatic cleanup goroutine. It is safe for concurrent use and
+// does nothing if the goroutine is already running or the storage was created with
+// WithLazyExpirationOnly.
 func (s *Storage) StartCleanup(interval time.Duration) {
-	if !s.cleanupRunning {
-		s.ctx, s.cancel = context.WithCancel(context.Background())
-		s.cleanupRunning = true
-		s.safeGo(func() {
-			s.cleanup(interval)
-		})
+	if s.lazyOnly {
+		return
 	}
+
+	s.cleanupMu.Lock()
+	defer s.cleanupMu.Unlock()
+	// Checking closing under cleanupMu orders this with the StopCleanup call of Close, so a
+	// goroutine started concurrently with Close is always stopped by it.
+	if s.cleanupRunning || atomic.LoadInt32(&s.closing) != 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.cleanupRunning, s.cancel, s.cleanupDone = true, cancel, done
+	s.safeGo(func() {
+		defer close(done)
+		s.cleanup(ctx, interval)
+	})
 }
 
-// StopCleanup stops the automatic cleanup goroutine gracefully.
+// StopCleanup stops the automatic cleanup goroutine gracefully and waits for it to exit.
+// It is safe for concurrent use and does nothing if the goroutine is not running.
 func (s *Storage) StopCleanup() {
-	if s.cleanupRunning {
-		s.cancel()
-		s.cleanupRunning = false
+	s.cleanupMu.Lock()
+	if !s.cleanupRunning {
+		s.cleanupMu.Unlock()
+		return
+	}
+	cancel, done := s.cancel, s.cleanupDone
+	s.cleanupRunning, s.cancel, s.cleanupDone = false, nil, nil
+	s.cleanupMu.Unlock()
+
+	cancel()
+	<-done
+}
+
+// isCleanupRunning reports whether the cleanup goroutine is running.
+func (s *Storage) isCleanupRunning() bool {
+	s.cleanupMu.Lock()
+	defer s.cleanupMu.Unlock()
+	return s.cleanupRunning
+}
+
+// Close stops the background work of storage, such as the cleanup goroutine and the idle
+// watcher, and applies the deletions queued with WithAsyncDelete, the writes buffered with
+// WithWriteCoalescing and the events buffered with WithEventCoalescing.
+//
+// Once Close returns, operations on entries fail with ErrClosed: reads and writes return
+// it, GetSlice reports it for every key, GetInto and HasMany report nothing found, and
+// Delete, DeleteMany, Reset, SoftReset, PurgeExpired, Compact, Restore and StartCleanup do
+// nothing. Introspection such as Len, Stats, MetricsSnapshot, Snapshot and Scan keeps
+// working on the entries left. Operations racing with Close may complete either way.
+// Calling Close again does nothing and returns nil.
+func (s *Storage) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closing, 0, 1) {
+		return nil
+	}
+	s.stopIdleWatch()
+	s.finishClose()
+	return nil
+}
+
+// finishClose stops the background work other than the idle watcher and marks storage
+// closed. The caller must have won the race to set closing.
+func (s *Storage) finishClose() {
+	s.StopCleanup()
+	s.stopCleanupPolicies()
+	s.stopAsyncDelete()
+	s.stopCoalescing()
+	s.stopEventCoalescing()
+	s.closeSubscribers()
+	atomic.StoreInt32(&s.closed, 1)
+}
+
+// isClosed reports whether Close has completed.
+func (s *Storage) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+// checkOpen returns ErrClosed if Close has completed, and otherwise records the operation
+// calling it for the idle watcher. Every public operation on entries calls it first.
+func (s *Storage) checkOpen() error {
+	s.markActive()
+	if s.isClosed() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// checkWritable returns ErrImmutable if key holds a live immutable item.
+// The caller must hold the lock.
+func (s *Storage) checkWritable(key string) error {
+	if item, exists := s.data[key]; exists && item.immutable && !item.isExpiredAt(s.now()) {
+		return ErrImmutable
+	}
+	return nil
+}
+
+// storeItem stores an item under key, replacing any previous item and exists loader answer.
+// A new item is stamped with its creation time; a cloned one keeps the original's.
+// The caller must hold the write lock.
+func (s *Storage) storeItem(key string, item *item) {
+	key = s.intern(key)
+	now := s.now()
+	if item.createdAt.IsZero() {
+		item.createdAt = now
+	}
+	item.expiration = s.capLifetime(item.createdAt, item.expiration)
+	s.lastVersion++
+	item.version = s.lastVersion
+	if s.maxEntries > 0 {
+		item.lastUsed = now.UnixNano()
+	}
+	if _, exists := s.data[key]; !exists {
+		atomic.AddInt64(&s.length, 1)
+	}
+	s.data[key] = item
+	if s.bloom != nil {
+		s.bloom.add(key)
+	}
+	s.scheduleExpiration(key, item.expiration)
+	delete(s.markers, key)
+	if len(s.data) > s.peakLen {
+		s.peakLen = len(s.data)
+	}
+	s.unlinkDependencies(key)
+	s.invalidateDependents(key)
+	s.publish(EventSet, key)
+}
+
+// removeItem removes the item stored under key. The caller must hold the write lock.
+func (s *Storage) removeItem(key string) {
+	s.removeItemAs(key, EventDelete)
+}
+
+// removeItemAs removes the item stored under key and publishes an event of type typ for it.
+// The caller must hold the write lock.
+func (s *Storage) removeItemAs(key string, typ EventType) {
+	if _, exists := s.data[key]; exists {
+		atomic.AddInt64(&s.length, -1)
+		s.recordTombstone(key)
+	}
+	delete(s.writeBuckets, key)
+	delete(s.data, key)
+	delete(s.interned, key)
+	if s.spaceFreed != nil {
+		s.spaceFreed.Broadcast()
+	}
+	s.unlinkDependencies(key)
+	s.invalidateDependents(key)
+	s.publish(typ, key)
+}
+
+// PurgeExpired removes all expired items from storage, except those still within the
+// stale window set by WithStaleWindow.
+func (s *Storage) PurgeExpired() {
+	if s.isClosed() {
+		return
+	}
+	s.removeExpiredItems()
+}
+
+// removeExpiredItem removes an expired item read by Get, unless it was replaced in the meantime.
+func (s *Storage) removeExpiredItem(key string, item *item) {
+	var expired []KeyValue
+	s.mu.Lock()
+	if s.data[key] == item {
+		expired = s.expireItem(key, item, expired)
+	}
+	s.mu.Unlock()
+	s.notifyExpired(expired)
+}
+
+// removeItems removes the given items, skipping those replaced since they were read.
+func (s *Storage) removeItems(items map[string]*item) {
+	s.mu.Lock()
+	for key, item := range items {
+		if s.data[key] == item {
+			s.removeItem(key)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// runCleanupPass runs one pass of the cleanup goroutine, bounded by the cleanup time budget if one is set.
+func (s *Storage) runCleanupPass() {
+	s.removeExpiredTombstones()
+	if s.wheel != nil {
+		s.advanceWheel()
+		return
+	}
+	if s.cleanupBudget > 0 {
+		s.removeExpiredItemsWithin(s.cleanupBudget)
+		return
 	}
+	s.removeExpiredItems()
+}
+
+// removeExpiredItemsWithin removes expired items until the budget elapses, resuming from
+// the same cursor on the next call. The cursor is refilled with a snapshot of the keys,
+// taken under the read lock, once it has been fully checked.
+func (s *Storage) removeExpiredItemsWithin(budget time.Duration) {
+	s.cursorMu.Lock()
+	defer s.cursorMu.Unlock()
+
+	if len(s.cleanupCursor) == 0 {
+		s.mu.RLock()
+		keys := make([]string, 0, len(s.data))
+		for key := range s.data {
+			keys = append(keys, key)
+		}
+		s.mu.RUnlock()
+		s.cleanupCursor = keys
+		s.removeExpiredMarkers()
+	}
+
+	var expired []KeyValue
+	defer func() { s.notifyExpired(expired) }()
+
+	start := s.now()
+	s.mu.Lock()
+	for i, key := range s.cleanupCursor {
+		if i > 0 && i%cleanupClockStride == 0 && s.now().Sub(start) >= budget {
+			s.cleanupCursor = s.cleanupCursor[i:]
+			s.mu.Unlock()
+			return
+		}
+		if item, exists := s.data[key]; exists && s.isReclaimable(item, start) {
+			expired = s.expireItem(key, item, expired)
+		}
+	}
+	s.cleanupCursor = nil
+	s.mu.Unlock()
+}
+
+// removeExpiredMarkers removes exists loader answers that have expired.
+func (s *Storage) removeExpiredMarkers() {
+	now := s.now()
+	s.mu.Lock()
+	for key, marker := range s.markers {
+		if marker.isExpiredAt(now) {
+			delete(s.markers, key)
+		}
+	}
+	s.mu.Unlock()
 }
 
 // removeExpiredItems removes items that have expired.
 func (s *Storage) removeExpiredItems() {
-	now := time.Now()
+	var expired []KeyValue
+	now := s.now()
 	s.mu.Lock()
 	for key, item := range s.data {
-		if item.isExpiredAt(now) {
-			delete(s.data, key)
+		if s.isReclaimable(item, now) {
+			expired = s.expireItem(key, item, expired)
+		}
+	}
+	for key, marker := range s.markers {
+		if marker.isExpiredAt(now) {
+			delete(s.markers, key)
 		}
 	}
 	s.mu.Unlock()
+	s.notifyExpired(expired)
+}
+
+// StoreError records the operation and key an error occurred on. Get, Set and the other
+// operations on a single key return their errors wrapped in a StoreError, so error reports
+// show what failed on which key. Unwrap returns the underlying error, so errors.Is still
+// matches sentinels such as ErrKeyNotFound and errors.As reaches the StoreError itself.
+type StoreError struct {
+	Op  string
+	Key string
+	Err error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.Op, e.Key, e.Err)
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// wrapOpError wraps the error err points to, if any, in a StoreError for op on key. Errors
+// that already are StoreErrors, from operations built on other operations, are kept as is.
+// It is meant to be deferred with a named error result.
+func wrapOpError(op, key string, err *error) {
+	var storeErr *StoreError
+	if *err == nil || errors.As(*err, &storeErr) {
+		return
+	}
+	*err = &StoreError{Op: op, Key: key, Err: *err}
+}
+
+// keyNotFound returns ErrKeyNotFound wrapped with the key, for errors.Is matching and better logs.
+func keyNotFound(key string) error {
+	return fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+}
+
+// keyExpired returns ErrKeyExpired wrapped with the key, for errors.Is matching and better logs.
+func keyExpired(key string) error {
+	return fmt.Errorf("%w: %q", ErrKeyExpired, key)
 }
 
 // validateKeyAndTTL checks if the key and TTL are valid.
@@ -151,18 +923,33 @@ func (s *Storage) validateKeyAndTTL(key string, ttl time.Duration) error {
 	if key == "" {
 		return ErrEmptyKey
 	}
-	if ttl < 0 {
+	return s.validateTTL(ttl)
+}
+
+// validateTTL checks if the TTL is valid.
+func (s *Storage) validateTTL(ttl time.Duration) error {
+	if ttl < 0 && ttl != DefaultTTL {
 		return ErrNegativeTTL
 	}
 	return nil
 }
 
-// calculateExpiration calculates the expiration time based on TTL.
+// calculateExpiration calculates the expiration time based on TTL, clamped to the maximum
+// TTL and rounded up to the expiration granularity if they are set.
 func (s *Storage) calculateExpiration(ttl time.Duration) time.Time {
+	if s.maxTTL > 0 && (ttl <= 0 || ttl > s.maxTTL) {
+		ttl = s.maxTTL
+	}
 	if ttl <= 0 {
 		return time.Time{}
 	}
-	return time.Now().Add(ttl)
+	expiration := s.now().Add(ttl)
+	if s.granularity > 0 {
+		if rounded := expiration.Truncate(s.granularity); rounded.Before(expiration) {
+			expiration = rounded.Add(s.granularity)
+		}
+	}
+	return expiration
 }
 
 // newItem creates a new item with the given value and expiration time.
@@ -173,9 +960,13 @@ func newItem(value interface{}, expiration time.Time) *item {
 	}
 }
 
-// isExpired checks if the item is expired.
-func (i *item) isExpired() bool {
-	return i.isExpiredAt(time.Now())
+// clone returns a copy of the item without its access statistics.
+func (i *item) clone() *item {
+	clone := newItem(i.value, i.expiration)
+	clone.createdAt = i.createdAt
+	clone.version = i.version
+	clone.immutable = i.immutable
+	return clone
 }
 
 // isExpiredAt checks if the item is expired at a specific time.
@@ -188,7 +979,7 @@ func (s *Storage) safeGo(f func()) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("Remo: [Panic] %v", r)
+				s.logger.Printf("Remo: [Panic] %v", r)
 			}
 		}()
 		f()
@@ -15,7 +15,10 @@
 package remo
 
 import (
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -42,13 +45,13 @@ func TestStorage_SetGetDelete(t *testing.T) {
 
 	// Test setting a key with a negative TTL.
 	err = store.Set("negativeTTLKey", "value", -time.Second)
-	if err != ErrNegativeTTL {
+	if !errors.Is(err, ErrNegativeTTL) {
 		t.Errorf("Expected ErrNegativeTTL, but got %v", err)
 	}
 
 	// Test setting a key with an empty name.
 	err = store.Set("", "value", time.Second)
-	if err != ErrEmptyKey {
+	if !errors.Is(err, ErrEmptyKey) {
 		t.Errorf("Expected ErrEmptyKey, but got %v", err)
 	}
 
@@ -72,7 +75,7 @@ func TestStorage_SetGetDelete(t *testing.T) {
 	// Test deleting a key.
 	store.Delete(key)
 	_, err = store.Get(key)
-	if err != ErrKeyNotFound {
+	if !errors.Is(err, ErrKeyNotFound) {
 		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
 	}
 
@@ -98,7 +101,7 @@ func TestStorage_Cleanup(t *testing.T) {
 	time.Sleep(2 * time.Second)
 
 	_, err = store.Get(key)
-	if err != ErrKeyNotFound {
+	if !errors.Is(err, ErrKeyNotFound) {
 		t.Errorf("Expected ErrKeyNotFound after cleanup, but got %v", err)
 	}
 
@@ -106,6 +109,91 @@ func TestStorage_Cleanup(t *testing.T) {
 	store.StopCleanup()
 }
 
+func TestStorage_StopCleanupWithoutStart(t *testing.T) {
+	// Test stopping cleanup that was never started.
+	store := New()
+	store.StopCleanup()
+	if store.isCleanupRunning() {
+		t.Errorf("Expected cleanup not to be running")
+	}
+
+	// Test stopping cleanup twice.
+	store.StartCleanup(10 * time.Millisecond)
+	store.StopCleanup()
+	store.StopCleanup()
+	if store.isCleanupRunning() {
+		t.Errorf("Expected cleanup not to be running")
+	}
+
+	// Test cleanup restarting after being stopped.
+	store.StartCleanup(10 * time.Millisecond)
+	if !store.isCleanupRunning() {
+		t.Errorf("Expected cleanup to be running again")
+	}
+	store.StopCleanup()
+}
+
+func TestStorage_AutoCleanup(t *testing.T) {
+	store := New(WithAutoCleanup(50 * time.Millisecond))
+	defer store.Close()
+
+	// Test cleanup running right after New.
+	if !store.cleanupRunning {
+		t.Fatal("Expected cleanup to be running after New")
+	}
+	store.Set("key", "value", 10*time.Millisecond)
+	waitFor(t, func() bool {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		return len(store.data) == 0
+	})
+
+	// Test Close stopping cleanup.
+	store.Close()
+	if store.cleanupRunning {
+		t.Errorf("Expected cleanup to be stopped after Close")
+	}
+}
+
+func TestStorage_CleanupStartJitter(t *testing.T) {
+	store := New(WithCleanupStartJitter(time.Second))
+	var requested int64
+	store.randInt63n = func(n int64) int64 {
+		requested = n
+		return int64(200 * time.Millisecond)
+	}
+
+	// Inject a clock recording when the first cleanup pass starts.
+	var mu sync.Mutex
+	var firstPass time.Time
+	store.now = func() time.Time {
+		now := time.Now()
+		mu.Lock()
+		if firstPass.IsZero() {
+			firstPass = now
+		}
+		mu.Unlock()
+		return now
+	}
+
+	start := time.Now()
+	store.StartCleanup(50 * time.Millisecond)
+	time.Sleep(400 * time.Millisecond)
+	store.StopCleanup()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requested != int64(time.Second) {
+		t.Errorf("Expected a jitter drawn from [0, 1s), but got a bound of %v", time.Duration(requested))
+	}
+	if firstPass.IsZero() {
+		t.Fatal("Expected a cleanup pass to run")
+	}
+	if delay := firstPass.Sub(start); delay < 250*time.Millisecond {
+		t.Errorf("Expected the first pass after the jitter plus one interval, but it ran after %v", delay)
+	}
+}
+
 func TestStorage_Reset(t *testing.T) {
 	store := New()
 
@@ -118,11 +206,33 @@ func TestStorage_Reset(t *testing.T) {
 
 	store.Reset()
 	_, err = store.Get(key)
-	if err != ErrKeyNotFound {
+	if !errors.Is(err, ErrKeyNotFound) {
 		t.Errorf("Expected ErrKeyNotFound after reset, but got %v", err)
 	}
 }
 
+func TestStorage_SoftReset(t *testing.T) {
+	store := New()
+	store.Set("key", "value", 0)
+	store.Get("key")
+	store.Get("missingKey")
+
+	// Test SoftReset removing entries but keeping the counters.
+	store.SoftReset()
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after SoftReset, but got %v", err)
+	}
+	if stats := store.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, but got %+v", stats)
+	}
+
+	// Test Reset zeroing the counters.
+	store.Reset()
+	if stats := store.Stats(); stats != (Stats{}) {
+		t.Errorf("Expected zeroed stats after Reset, but got %+v", stats)
+	}
+}
+
 func TestStorage_ErrKeyExpired(t *testing.T) {
 	store := New()
 
@@ -135,9 +245,118 @@ func TestStorage_ErrKeyExpired(t *testing.T) {
 
 	time.Sleep(200 * time.Millisecond)
 	_, err = store.Get(key)
-	if err != ErrKeyExpired {
+	if !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
+
+// Test that lookup errors name the key while still matching the sentinel errors.
+func TestStorage_ErrorWrapsKey(t *testing.T) {
+	store := New()
+
+	_, err := store.Get("missingKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), `"missingKey"`) {
+		t.Errorf("Expected error to contain the key, but got %v", err)
+	}
+
+	if err := store.Set("expiredKey", "value", time.Millisecond); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = store.Get("expiredKey")
+	if !errors.Is(err, ErrKeyExpired) {
 		t.Errorf("Expected ErrKeyExpired, but got %v", err)
 	}
+	if err == nil || !strings.Contains(err.Error(), `"expiredKey"`) {
+		t.Errorf("Expected error to contain the key, but got %v", err)
+	}
+}
+
+func TestStorage_GetInto(t *testing.T) {
+	store := New()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Set("key", "value", 0)
+	store.Set("expiringKey", "value", time.Second)
+
+	// Test a hit filling dst.
+	var value interface{}
+	if !store.GetInto("key", &value) || value != "value" {
+		t.Errorf("Expected a hit with value, but got %v", value)
+	}
+
+	// Test misses leaving dst unchanged.
+	now = now.Add(2 * time.Second)
+	if store.GetInto("missingKey", &value) || store.GetInto("expiringKey", &value) || value != "value" {
+		t.Errorf("Expected misses leaving dst unchanged, but got %v", value)
+	}
+	if _, exists := store.data["expiringKey"]; exists {
+		t.Errorf("Expected the expired key to be removed")
+	}
+	if stats := store.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, but got %+v", stats)
+	}
+}
+
+func TestStorage_MustGet(t *testing.T) {
+	store := New()
+	store.Set("key", "value", 0)
+
+	// Test the value being returned when present.
+	if value := store.MustGet("key"); value != "value" {
+		t.Errorf("Expected value, but got %v", value)
+	}
+
+	// Test a missing key panicking with its name.
+	defer func() {
+		r := recover()
+		if message, ok := r.(string); !ok || !strings.Contains(message, `"missingKey"`) {
+			t.Errorf("Expected a panic naming missingKey, but got %v", r)
+		}
+	}()
+	store.MustGet("missingKey")
+	t.Errorf("Expected MustGet to panic")
+}
+
+func TestStorage_StoreError(t *testing.T) {
+	store := New()
+
+	// Test errors carrying the operation and key while still matching sentinels.
+	_, err := store.Get("missingKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	storeErr, ok := err.(*StoreError)
+	if !ok || storeErr.Op != "Get" || storeErr.Key != "missingKey" {
+		t.Fatalf("Expected a StoreError for Get on missingKey, but got %#v", err)
+	}
+
+	err = store.Set("key", "value", -time.Second)
+	if !errors.Is(err, ErrNegativeTTL) {
+		t.Errorf("Expected ErrNegativeTTL, but got %v", err)
+	}
+	if !errors.As(err, &storeErr) || storeErr.Op != "Set" || storeErr.Key != "key" {
+		t.Errorf("Expected a StoreError for Set on key, but got %#v", err)
+	}
+
+	// Test operations beyond Get and Set wrapping their errors too.
+	err = store.RingPush("key", "event", 0, 0)
+	if !errors.As(err, &storeErr) || storeErr.Op != "RingPush" || storeErr.Key != "key" {
+		t.Errorf("Expected a StoreError for RingPush on key, but got %#v", err)
+	}
+	err = store.MoveTo(New(), "missingKey")
+	if !errors.As(err, &storeErr) || storeErr.Op != "MoveTo" || storeErr.Key != "missingKey" {
+		t.Errorf("Expected a StoreError for MoveTo on missingKey, but got %#v", err)
+	}
+
+	// Test successful operations returning nil rather than an empty StoreError.
+	if err := store.Set("key", "value", 0); err != nil {
+		t.Errorf("Expected nil, but got %#v", err)
+	}
 }
 
 func TestStorage_ConcurrentAccess(t *testing.T) {
@@ -170,7 +389,7 @@ func TestStorage_ConcurrentAccess(t *testing.T) {
 			defer wg.Done()
 			for j := 0; j < numOperationsPerRoutine; j++ {
 				retrievedValue, err := store.Get(key)
-				if err != nil && err != ErrKeyNotFound && err != ErrKeyExpired {
+				if err != nil && !errors.Is(err, ErrKeyNotFound) && !errors.Is(err, ErrKeyExpired) {
 					t.Errorf("Get() failed: %v", err)
 				}
 				if err == nil && retrievedValue != value {
@@ -205,6 +424,30 @@ func TestStorage_ConcurrentAccess(t *testing.T) {
 	wg.Wait()
 }
 
+// Test that recycled items come back from the pool fully reset and stored values are intact.
+func TestStorage_ItemPool(t *testing.T) {
+	store := New(WithItemPool(), WithSkipUnchangedWrites())
+	store.Set("key", "value", 0)
+	stored := store.data["key"]
+	for i := 0; i < 10; i++ {
+		store.Set("key", "value", 0)
+	}
+	if store.data["key"] != stored {
+		t.Errorf("Expected the stored item to be kept")
+	}
+	if value, err := store.Get("key"); err != nil || value != "value" {
+		t.Errorf("Expected value, but got %v, %v", value, err)
+	}
+
+	used := store.allocItem("old", time.Now())
+	used.immutable = true
+	used.version = 7
+	store.recycleItem(used)
+	if *used != (item{}) {
+		t.Errorf("Expected a recycled item to be reset, but got %+v", *used)
+	}
+}
+
 // BenchmarkSet measures the performance of the Set operation.
 func BenchmarkSet(b *testing.B) {
 	store := New()
@@ -219,6 +462,33 @@ func BenchmarkSet(b *testing.B) {
 	}
 }
 
+// BenchmarkSetChurn measures allocations of repeated writes to a few hot keys that are
+// coalesced, with and without the item pool.
+func BenchmarkSetChurn(b *testing.B) {
+	keys := []string{"hot:0", "hot:1", "hot:2", "hot:3"}
+	value := "benchmarkValue"
+	for _, bm := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Plain", nil},
+		{"Pooled", []Option{WithItemPool()}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			opts := append([]Option{WithWriteCoalescing(time.Hour, func(string) bool { return true })}, bm.opts...)
+			store := New(opts...)
+			defer store.Close()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.Set(keys[i%len(keys)], value, 0); err != nil {
+					b.Fatalf("Set() failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkGet measures the performance of the Get operation.
 func BenchmarkGet(b *testing.B) {
 	store := New()
@@ -239,6 +509,34 @@ func BenchmarkGet(b *testing.B) {
 	}
 }
 
+// BenchmarkGetInto compares the allocations of Get and GetInto on hits.
+func BenchmarkGetInto(b *testing.B) {
+	store := New()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("benchmarkKey%d", i)
+		store.Set(keys[i], "benchmarkValue", time.Hour)
+	}
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.Get(keys[i%len(keys)]); err != nil {
+				b.Fatalf("Get() failed: %v", err)
+			}
+		}
+	})
+	b.Run("GetInto", func(b *testing.B) {
+		b.ReportAllocs()
+		var value interface{}
+		for i := 0; i < b.N; i++ {
+			if !store.GetInto(keys[i%len(keys)], &value) {
+				b.Fatalf("GetInto() missed")
+			}
+		}
+	})
+}
+
 // BenchmarkDelete measures the performance of the Delete operation.
 func BenchmarkDelete(b *testing.B) {
 	store := New()
@@ -277,3 +575,319 @@ func BenchmarkReset(b *testing.B) {
 		store.Reset()
 	}
 }
+
+func TestStorage_LazyExpirationOnly(t *testing.T) {
+	store := New(WithLazyExpirationOnly())
+
+	// Test StartCleanup not starting a goroutine.
+	before := runtime.NumGoroutine()
+	store.StartCleanup(10 * time.Millisecond)
+	if store.cleanupRunning || runtime.NumGoroutine() != before {
+		t.Errorf("Expected no cleanup goroutine to be started")
+	}
+
+	store.Set("expiredKey", "value", 50*time.Millisecond)
+	store.Set("unreadKey", "value", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	// Test Get collecting the expired entry.
+	_, err := store.Get("expiredKey")
+	if !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+	if _, exists := store.data["expiredKey"]; exists {
+		t.Errorf("Expected expiredKey to be removed on access")
+	}
+
+	// Test unread expired entries persisting until PurgeExpired.
+	if _, exists := store.data["unreadKey"]; !exists {
+		t.Errorf("Expected unreadKey to persist without reads")
+	}
+	store.PurgeExpired()
+	if _, exists := store.data["unreadKey"]; exists {
+		t.Errorf("Expected unreadKey to be removed by PurgeExpired")
+	}
+}
+
+func TestStorage_CleanupTimeBudget(t *testing.T) {
+	store := New(WithCleanupTimeBudget(time.Nanosecond))
+
+	const numKeys = 1000
+	for i := 0; i < numKeys; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 50*time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Test a single pass stopping once the budget elapses.
+	store.runCleanupPass()
+	remaining := len(store.data)
+	if remaining == 0 || remaining == numKeys {
+		t.Fatalf("Expected a partial pass, but %d of %d keys remain", remaining, numKeys)
+	}
+
+	// Test later passes resuming until every expired key is removed.
+	passes := 1
+	for len(store.data) > 0 && passes < numKeys {
+		store.runCleanupPass()
+		passes++
+	}
+	if len(store.data) != 0 {
+		t.Errorf("Expected all expired keys to be removed, but %d remain", len(store.data))
+	}
+	if passes < numKeys/cleanupClockStride {
+		t.Errorf("Expected multiple passes, but got %d", passes)
+	}
+}
+
+func TestStorage_SetWithGeneration(t *testing.T) {
+	store := New()
+
+	generation, err := store.SetWithGeneration("key", "value", 0)
+	if err != nil {
+		t.Fatalf("SetWithGeneration() failed: %v", err)
+	}
+	if generation != store.Generation() {
+		t.Errorf("Expected generation %d, but got %d", store.Generation(), generation)
+	}
+
+	// Test a write discarded by Reset being detectable even though Set succeeded.
+	store.Reset()
+	if store.Generation() == generation {
+		t.Errorf("Expected Reset to start a new generation")
+	}
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after reset, but got %v", err)
+	}
+
+	// Test a write racing Reset reporting the generation it landed in.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		store.Reset()
+	}()
+	generation, _ = store.SetWithGeneration("racingKey", "value", 0)
+	wg.Wait()
+
+	_, err = store.Get("racingKey")
+	if generation == store.Generation() && err != nil {
+		t.Errorf("Expected a write in the current generation to be stored, but got %v", err)
+	}
+	if generation != store.Generation() && !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected a write in a past generation to be reset, but got %v", err)
+	}
+}
+
+func TestStorage_SetOnce(t *testing.T) {
+	store := New()
+
+	// Test the first set succeeding.
+	err := store.SetOnce("key", "value", 0)
+	if err != nil {
+		t.Fatalf("SetOnce() failed: %v", err)
+	}
+	value, _ := store.Get("key")
+	if value != "value" {
+		t.Errorf("Expected value, but got %v", value)
+	}
+
+	// Test every later write failing.
+	if err := store.SetOnce("key", "newValue", 0); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected ErrImmutable from SetOnce, but got %v", err)
+	}
+	if err := store.Set("key", "newValue", 0); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected ErrImmutable from Set, but got %v", err)
+	}
+	if _, err := store.CompareAndSwap("key", "value", "newValue"); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected ErrImmutable from CompareAndSwap, but got %v", err)
+	}
+	if err := store.RingPush("key", "newValue", 3, 0); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected ErrImmutable from RingPush, but got %v", err)
+	}
+	value, _ = store.Get("key")
+	if value != "value" {
+		t.Errorf("Expected value to be unchanged, but got %v", value)
+	}
+
+	// Test delete-then-set working again.
+	store.Delete("key")
+	if err := store.SetOnce("key", "newValue", 0); err != nil {
+		t.Errorf("Expected SetOnce to succeed after Delete, but got %v", err)
+	}
+
+	// Test an expired immutable key being writable.
+	store.SetOnce("expiringKey", "value", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if err := store.Set("expiringKey", "newValue", 0); err != nil {
+		t.Errorf("Expected Set to succeed after expiry, but got %v", err)
+	}
+}
+
+func TestStorage_SetInterceptor(t *testing.T) {
+	errEmptyValue := errors.New("empty value")
+	store := New(WithSetInterceptor(func(key string, value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, errEmptyValue
+		}
+		return s, nil
+	}))
+
+	// Test the interceptor transforming the value.
+	if err := store.Set("key", "  value  ", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if value, _ := store.Get("key"); value != "value" {
+		t.Errorf("Expected %q, but got %q", "value", value)
+	}
+
+	// Test the interceptor vetoing the write and leaving the stored value intact.
+	if err := store.Set("key", "   ", 0); !errors.Is(err, errEmptyValue) {
+		t.Errorf("Expected errEmptyValue, but got %v", err)
+	}
+	if value, _ := store.Get("key"); value != "value" {
+		t.Errorf("Expected %q, but got %q", "value", value)
+	}
+	if err := store.Set("newKey", "", 0); !errors.Is(err, errEmptyValue) {
+		t.Errorf("Expected errEmptyValue, but got %v", err)
+	}
+	if _, err := store.Get("newKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+
+	// Test the interceptor passing other values through.
+	if err := store.Set("intKey", 42, 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if value, _ := store.Get("intKey"); value != 42 {
+		t.Errorf("Expected 42, but got %v", value)
+	}
+}
+
+func TestStorage_SkipUnchangedWrites(t *testing.T) {
+	store := New(WithSkipUnchangedWrites(), WithAccessTracking())
+	store.Set("parent", "value", 0)
+	store.SetWithDependency("child", "value", 0, "parent")
+	store.Get("parent")
+
+	// Test an unchanged write leaving the entry and its dependents untouched.
+	if err := store.Set("parent", "value", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if isMissing(store, "child") {
+		t.Errorf("Expected child to survive an unchanged write")
+	}
+	if hits := store.TopKeys(1)[0].Hits; hits != 1 {
+		t.Errorf("Expected the access statistics to be kept, but got %d hits", hits)
+	}
+
+	// Test a write with a TTL still applying.
+	if err := store.Set("parent", "value", time.Minute); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	store.mu.RLock()
+	expiration := store.data["parent"].expiration
+	store.mu.RUnlock()
+	if expiration.IsZero() {
+		t.Errorf("Expected the TTL update to apply")
+	}
+	waitFor(t, func() bool { return isMissing(store, "child") })
+
+	// Test a changed write applying and invalidating dependents.
+	store.Set("parent", "value", 0)
+	store.SetWithDependency("child", "value", 0, "parent")
+	if err := store.Set("parent", "newValue", 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if value, _ := store.Get("parent"); value != "newValue" {
+		t.Errorf("Expected newValue, but got %v", value)
+	}
+	waitFor(t, func() bool { return isMissing(store, "child") })
+
+	// Test values that cannot be compared always being written.
+	if err := store.Set("slice", []int{1}, 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := store.Set("slice", []int{1}, 0); err != nil {
+		t.Errorf("Expected a non-comparable value to be written, but got %v", err)
+	}
+}
+
+// FuzzStorage_Lifecycle interleaves operations, including cleanup and lifecycle calls,
+// from several goroutines and checks that they neither panic nor deadlock, leave storage
+// consistent and leak no goroutines once storage is closed. Each byte of the input selects
+// an operation and its key; the goroutines share the operations round-robin.
+func FuzzStorage_Lifecycle(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add([]byte{4, 5, 5, 4, 6, 7, 6, 0, 1, 3})
+	f.Add([]byte{7, 7, 5, 5, 0, 8, 16, 24, 32, 9, 17, 25})
+	f.Add([]byte("\x00\x10\x20\x30\x04\x14\x05\x15\x06\x16\x07\x17"))
+
+	const numGoroutines = 4
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		if len(ops) > 256 {
+			ops = ops[:256]
+		}
+		before := runtime.NumGoroutine()
+		store := New(WithMaxEntries(8))
+
+		var wg sync.WaitGroup
+		for g := 0; g < numGoroutines; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := g; i < len(ops); i += numGoroutines {
+					op := ops[i]
+					key := fmt.Sprintf("key%d", op>>4)
+					switch op & 0x0f {
+					case 0:
+						store.Set(key, i, 0)
+					case 1:
+						// A TTL short enough to expire while the operations run.
+						store.Set(key, i, time.Duration(op>>4)*time.Millisecond)
+					case 2:
+						store.Get(key)
+					case 3:
+						store.Delete(key)
+					case 4:
+						store.StartCleanup(time.Millisecond)
+					case 5:
+						store.StopCleanup()
+					case 6:
+						store.Close()
+					case 7:
+						store.Reset()
+					case 8:
+						store.Increment(key, 1, time.Millisecond)
+					case 9:
+						time.Sleep(time.Millisecond)
+					default:
+						store.Has(key)
+					}
+				}
+			}(g)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Operations deadlocked")
+		}
+
+		if err := store.Validate(); err != nil {
+			t.Errorf("Validate() failed: %v", err)
+		}
+		store.Close()
+		waitFor(t, func() bool { return runtime.NumGoroutine() <= before })
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"time"
+)
+
+// RingPush appends value to the ring stored under key, keeping only the most recent maxLen
+// values, and sets the TTL of the whole ring. A missing or expired key starts a new ring.
+// It returns ErrWrongType if key holds a value that is not a ring.
+func (s *Storage) RingPush(key string, value interface{}, maxLen int, ttl time.Duration) (err error) {
+	defer wrapOpError("RingPush", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return err
+	}
+	if maxLen <= 0 {
+		return ErrInvalidMaxLen
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if err := s.makeRoom(context.Background(), key); err != nil {
+		return err
+	}
+	if err := s.checkWritable(key); err != nil {
+		return err
+	}
+
+	var values []interface{}
+	if item, exists := s.data[key]; exists && !item.isExpiredAt(s.now()) {
+		current, err := s.decode(item.value)
+		if err != nil {
+			return err
+		}
+		ring, ok := current.([]interface{})
+		if !ok {
+			return ErrWrongType
+		}
+		values = ring
+	}
+
+	// Copy rather than append in place, so rings returned earlier never change.
+	if len(values) >= maxLen {
+		values = values[len(values)-maxLen+1:]
+	}
+	ring := make([]interface{}, len(values), len(values)+1)
+	copy(ring, values)
+	ring = append(ring, value)
+
+	ttl, err = s.resolveTTL(key, ring, ttl)
+	if err != nil {
+		return err
+	}
+	stored, err := s.encode(ring)
+	if err != nil {
+		return err
+	}
+	if err := s.checkValueSize(stored); err != nil {
+		return err
+	}
+	s.storeItem(key, newItem(stored, s.calculateExpiration(ttl)))
+	return nil
+}
+
+// RingGet returns a copy of the values in the ring stored under key, oldest first.
+func (s *Storage) RingGet(key string) (values []interface{}, err error) {
+	defer wrapOpError("RingGet", key, &err)
+	value, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	ring, ok := value.([]interface{})
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return append([]interface{}(nil), ring...), nil
+}
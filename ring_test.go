@@ -0,0 +1,99 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStorage_Ring(t *testing.T) {
+	store := New()
+
+	for i := 0; i < 5; i++ {
+		if err := store.RingPush("events", i, 3, 0); err != nil {
+			t.Fatalf("RingPush() failed: %v", err)
+		}
+	}
+
+	// Test trimming at the cap keeping the most recent values oldest-first.
+	values, err := store.RingGet("events")
+	if err != nil {
+		t.Fatalf("RingGet() failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{2, 3, 4}) {
+		t.Errorf("Expected [2 3 4], but got %v", values)
+	}
+
+	// Test the returned slice being a copy.
+	values[0] = "mutated"
+	values, _ = store.RingGet("events")
+	if values[0] != 2 {
+		t.Errorf("Expected the ring to be unaffected by callers, but got %v", values)
+	}
+
+	// Test a missing key.
+	_, err = store.RingGet("missingKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+
+	// Test a key holding another type.
+	store.Set("stringKey", "value", 0)
+	if err := store.RingPush("stringKey", 1, 3, 0); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, but got %v", err)
+	}
+	if _, err := store.RingGet("stringKey"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, but got %v", err)
+	}
+
+	// Test an invalid max length.
+	if err := store.RingPush("events", 1, 0, 0); !errors.Is(err, ErrInvalidMaxLen) {
+		t.Errorf("Expected ErrInvalidMaxLen, but got %v", err)
+	}
+}
+
+func TestStorage_RingTTL(t *testing.T) {
+	store := New()
+
+	store.RingPush("events", 1, 3, 100*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+
+	// Test a push extending the TTL of the whole ring.
+	store.RingPush("events", 2, 3, 100*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	values, err := store.RingGet("events")
+	if err != nil {
+		t.Fatalf("RingGet() failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{1, 2}) {
+		t.Errorf("Expected [1 2], but got %v", values)
+	}
+
+	// Test the whole ring expiring.
+	time.Sleep(100 * time.Millisecond)
+	if _, err := store.RingGet("events"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+
+	// Test an expired ring being restarted.
+	store.RingPush("events", 3, 3, 0)
+	values, _ = store.RingGet("events")
+	if !reflect.DeepEqual(values, []interface{}{3}) {
+		t.Errorf("Expected [3], but got %v", values)
+	}
+}
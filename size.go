@@ -0,0 +1,92 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "reflect"
+
+// maxSizeDepth bounds how deep estimateSize follows references, which also protects it from cycles.
+const maxSizeDepth = 8
+
+// estimateSize returns an estimate of the bytes held by value. It is exact for strings and
+// byte slices. For other types it adds up the size of the value and of what it references
+// through pointers, slices, maps and interfaces, a few levels deep; shared references are
+// counted once per path, and memory private to the runtime, such as map buckets, is ignored.
+func estimateSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	v := reflect.ValueOf(value)
+	return int64(v.Type().Size()) + referencedSize(v, 0)
+}
+
+// referencedSize returns the estimated bytes referenced by v, excluding v itself.
+func referencedSize(v reflect.Value, depth int) int64 {
+	if depth >= maxSizeDepth {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + referencedSize(elem, depth+1)
+	case reflect.Slice:
+		size := int64(v.Len()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), depth+1)
+		}
+		return size
+	case reflect.Array:
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), depth+1)
+		}
+		return size
+	case reflect.Map:
+		var size int64
+		iter := v.MapRange()
+		for iter.Next() {
+			key, value := iter.Key(), iter.Value()
+			size += int64(key.Type().Size()) + referencedSize(key, depth+1)
+			size += int64(value.Type().Size()) + referencedSize(value, depth+1)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += referencedSize(v.Field(i), depth+1)
+		}
+		return size
+	}
+	return 0
+}
+
+// checkValueSize returns ErrValueTooLarge if a value in its stored form exceeds the
+// configured maximum size.
+func (s *Storage) checkValueSize(stored interface{}) error {
+	if s.maxValueBytes > 0 && estimateSize(stored) > s.maxValueBytes {
+		return ErrValueTooLarge
+	}
+	return nil
+}
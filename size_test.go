@@ -0,0 +1,75 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStorage_MaxValueBytes(t *testing.T) {
+	store := New(WithMaxValueBytes(16))
+
+	// Test values under and at the limit.
+	if err := store.Set("stringKey", strings.Repeat("a", 16), 0); err != nil {
+		t.Errorf("Expected a 16-byte string to be accepted, but got %v", err)
+	}
+	if err := store.Set("bytesKey", make([]byte, 10), 0); err != nil {
+		t.Errorf("Expected a 10-byte slice to be accepted, but got %v", err)
+	}
+
+	// Test values over the limit.
+	if err := store.Set("stringKey", strings.Repeat("a", 17), 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge for a string, but got %v", err)
+	}
+	if err := store.Set("bytesKey", make([]byte, 17), 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge for a byte slice, but got %v", err)
+	}
+	if _, err := store.CompareAndSwap("stringKey", strings.Repeat("a", 16), strings.Repeat("b", 32)); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge from CompareAndSwap, but got %v", err)
+	}
+
+	// Test rejected values leaving the stored ones intact.
+	value, _ := store.Get("bytesKey")
+	if len(value.([]byte)) != 10 {
+		t.Errorf("Expected the 10-byte slice to remain, but got %v", value)
+	}
+
+	// Test the check being disabled by default.
+	store = New()
+	if err := store.Set("key", strings.Repeat("a", 1<<20), 0); err != nil {
+		t.Errorf("Expected no limit by default, but got %v", err)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	type record struct {
+		Name string
+		Tags []string
+	}
+
+	small := estimateSize(record{Name: "a"})
+	large := estimateSize(record{Name: strings.Repeat("a", 1000), Tags: []string{strings.Repeat("b", 1000)}})
+	if large-small < 2000 {
+		t.Errorf("Expected referenced data to be counted, got %d and %d", small, large)
+	}
+
+	// Test self-referencing values not recursing forever.
+	type node struct{ next *node }
+	n := &node{}
+	n.next = n
+	estimateSize(n)
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "sync/atomic"
+
+// Snapshot is a point-in-time copy of a storage's live entries, including their absolute
+// expirations. It can be iterated with Range while writers change storage: entries set,
+// deleted or expired after it was taken do not affect it.
+//
+// Values are shared with storage, not copied, so a snapshot is lossless for any value type
+// but is not a deep copy. A value mutated in place after the snapshot was taken, such as a
+// pointer passed to UpdateInPlace or a slice changed by a holder, shows the change in the
+// snapshot too. With WithSerializedValues, values are stored encoded and never mutated in
+// place, so the snapshot does show storage exactly as it was when taken.
+type Snapshot struct {
+	data  map[string]*item
+	store *Storage
+}
+
+// Snapshot captures the current contents of storage. The entries are copied eagerly under
+// the read lock, so a snapshot costs a map and a small entry header per live entry, about
+// as much memory as the storage's own index; the values themselves are shared, not copied,
+// as described on Snapshot.
+func (s *Storage) Snapshot() *Snapshot {
+	now := s.now()
+	s.mu.RLock()
+	data := make(map[string]*item, len(s.data))
+	for key, item := range s.data {
+		if !item.isExpiredAt(now) {
+			data[key] = item.clone()
+		}
+	}
+	s.mu.RUnlock()
+	return &Snapshot{data: data, store: s}
+}
+
+// Len returns the number of entries in the snapshot.
+func (snap *Snapshot) Len() int {
+	return len(snap.data)
+}
+
+// Range calls fn for each entry in the snapshot, in no particular order, until fn returns
+// false. Entries that were live when the snapshot was taken are visited even if they have
+// expired since. Values are read as by Get, and entries whose value cannot be decoded are
+// skipped and logged.
+func (snap *Snapshot) Range(fn func(info KeyInfo) bool) {
+	for key, item := range snap.data {
+		value, err := snap.store.readValue(item)
+		if err != nil {
+			snap.store.logger.Printf("Remo: [Snapshot] skipping key %q: %v", key, err)
+			continue
+		}
+		if !fn(KeyInfo{Key: key, Value: value, Expiration: item.expiration, CreatedAt: item.createdAt}) {
+			return
+		}
+	}
+}
+
+// Restore atomically replaces the contents of storage with the snapshot and starts a new generation.
+// Entries that have expired since the snapshot was taken are skipped. A snapshot of another
+// storage may be restored: its values are re-encoded for the codec of this one, and entries
+// that fail to re-encode are skipped and logged. Like Reset, Restore discards buffered
+// coalesced writes, loader errors and write rate buckets.
+func (s *Storage) Restore(snap *Snapshot) {
+	if s.isClosed() {
+		return
+	}
+	now := s.now()
+	data := make(map[string]*item, len(snap.data))
+	for key, item := range snap.data {
+		if item.isExpiredAt(now) {
+			continue
+		}
+		restored := item.clone()
+		if snap.store != s {
+			stored, err := s.reencode(snap.store, item.value)
+			if err != nil {
+				s.logger.Printf("Remo: [Restore] skipping key %q: %v", key, err)
+				continue
+			}
+			restored.value = stored
+		}
+		data[key] = restored
+	}
+
+	s.mu.Lock()
+	s.dropPendingWrites()
+	s.loadErrors = nil
+	s.writeBuckets = nil
+	s.data = data
+	atomic.StoreInt64(&s.length, int64(len(data)))
+	s.markers = make(map[string]*item)
+	s.dependencies = make(map[string][]string)
+	s.dependents = make(map[string]map[string]struct{})
+	s.resetInterned(data)
+	s.resetWheel(data)
+	s.resetBloom(data)
+	if s.spaceFreed != nil {
+		s.spaceFreed.Broadcast()
+	}
+	s.peakLen = len(data)
+	s.generation++
+	s.mu.Unlock()
+}
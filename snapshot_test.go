@@ -0,0 +1,206 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStorage_SnapshotRestore(t *testing.T) {
+	store := New()
+
+	store.Set("permanentKey", "permanentValue", 0)
+	store.Set("ttlKey", []int{1, 2, 3}, time.Minute)
+	store.Set("shortKey", "shortValue", 100*time.Millisecond)
+
+	snap := store.Snapshot()
+
+	// Mutate the storage after the snapshot was taken.
+	store.Delete("permanentKey")
+	store.Set("ttlKey", "changedValue", 0)
+	store.Set("newKey", "newValue", 0)
+
+	time.Sleep(200 * time.Millisecond)
+	store.Restore(snap)
+
+	value, err := store.Get("permanentKey")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if value != "permanentValue" {
+		t.Errorf("Expected permanentValue, but got %v", value)
+	}
+
+	value, err = store.Get("ttlKey")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if slice, ok := value.([]int); !ok || len(slice) != 3 {
+		t.Errorf("Expected the original slice, but got %v", value)
+	}
+	if exp := store.data["ttlKey"].expiration; exp.Sub(time.Now()) <= 50*time.Second {
+		t.Errorf("Expected the original expiration to be restored, but got %v", exp)
+	}
+
+	// Test entries expired at restore time being skipped.
+	_, err = store.Get("shortKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for expired entry, but got %v", err)
+	}
+
+	// Test entries created after the snapshot being discarded.
+	_, err = store.Get("newKey")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for newKey, but got %v", err)
+	}
+
+	// Test the snapshot staying unchanged by writes after a restore.
+	store.Set("permanentKey", "overwritten", 0)
+	store.Restore(snap)
+	value, _ = store.Get("permanentKey")
+	if value != "permanentValue" {
+		t.Errorf("Expected permanentValue after second restore, but got %v", value)
+	}
+}
+
+// Test that a snapshot can be restored into storage with another codec.
+func TestStorage_RestoreAcrossCodecs(t *testing.T) {
+	plain := New()
+	plain.Set("key", "value", 0)
+	serialized := New(WithSerializedValues(GobCodec{}))
+	serialized.Restore(plain.Snapshot())
+	if value, err := serialized.Get("key"); err != nil || value != "value" {
+		t.Errorf("Expected value, got %v, %v", value, err)
+	}
+
+	plain.Restore(serialized.Snapshot())
+	if value, err := plain.Get("key"); err != nil || value != "value" {
+		t.Errorf("Expected value back in plain storage, got %v, %v", value, err)
+	}
+}
+
+// Test that Restore discards buffered writes, loader errors and write rate buckets.
+func TestStorage_RestoreClearsState(t *testing.T) {
+	store := New(
+		WithWriteCoalescing(time.Hour, func(key string) bool { return key == "buffered" }),
+		WithPerKeyWriteRate(1, time.Hour),
+	)
+	defer store.Close()
+	snap := store.Snapshot()
+	store.Set("limited", "value", 0)
+	store.Set("buffered", "value", 0)
+	store.recordLoad("failed", errors.New("load failed"))
+
+	store.Restore(snap)
+	store.flushWrites()
+	if _, err := store.Get("buffered"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected the buffered write to be discarded, but got %v", err)
+	}
+	if err, _, ok := store.LastError("failed"); ok {
+		t.Errorf("Expected the loader error to be cleared, but got %v", err)
+	}
+	if err := store.Set("limited", "value", 0); err != nil {
+		t.Errorf("Expected a fresh write rate bucket, but got %v", err)
+	}
+}
+
+func TestSnapshot_Range(t *testing.T) {
+	store := New()
+	for i := 0; i < 100; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	store.Set("expiredKey", "value", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	snap := store.Snapshot()
+	if snap.Len() != 100 {
+		t.Errorf("Expected 100 live entries, but got %d", snap.Len())
+	}
+
+	// Mutate the storage while the snapshot is iterated.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			store.Set(fmt.Sprintf("key%d", i), -i, 0)
+			store.Delete(fmt.Sprintf("key%d", (i+50)%100))
+			store.Set(fmt.Sprintf("newKey%d", i), i, 0)
+		}
+	}()
+
+	// Test the snapshot yielding its original entries unchanged.
+	seen := make(map[string]bool)
+	snap.Range(func(info KeyInfo) bool {
+		if info.Value != originalValue(info.Key) {
+			t.Errorf("Expected the original value of %s, but got %v", info.Key, info.Value)
+		}
+		seen[info.Key] = true
+		return true
+	})
+	<-done
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 entries, but got %d", len(seen))
+	}
+
+	// Test Range stopping when fn returns false.
+	visited := 0
+	snap.Range(func(info KeyInfo) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("Expected 1 visited entry, but got %d", visited)
+	}
+}
+
+// originalValue returns the value TestSnapshot_Range originally stores under key.
+func originalValue(key string) interface{} {
+	var i int
+	fmt.Sscanf(key, "key%d", &i)
+	return i
+}
+
+// Test that values are shared with storage, except with WithSerializedValues.
+func TestStorage_SnapshotSharesValues(t *testing.T) {
+	type counter struct{ N int }
+	increment := func(value interface{}) error {
+		value.(*counter).N++
+		return nil
+	}
+
+	store := New()
+	store.Set("counter", &counter{N: 1}, 0)
+	snap := store.Snapshot()
+	store.UpdateInPlace("counter", increment)
+	snap.Range(func(info KeyInfo) bool {
+		if n := info.Value.(*counter).N; n != 2 {
+			t.Errorf("Expected the shared value to show N=2, but got %d", n)
+		}
+		return true
+	})
+
+	serialized := New(WithSerializedValues(GobCodec{}))
+	serialized.Set("counter", &counter{N: 1}, 0)
+	snap = serialized.Snapshot()
+	serialized.UpdateInPlace("counter", increment)
+	snap.Range(func(info KeyInfo) bool {
+		if n := info.Value.(*counter).N; n != 1 {
+			t.Errorf("Expected the encoded value to keep N=1, but got %d", n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds counters describing the activity of storage.
+type Stats struct {
+	// Hits and Misses count reads that found a live value and reads that did not.
+	Hits   uint64
+	Misses uint64
+
+	// CleanupOverruns counts cleanup passes that took longer than the cleanup interval,
+	// and SkippedCleanups the ticks skipped after them to let other operations through.
+	CleanupOverruns uint64
+	SkippedCleanups uint64
+
+	// Evictions counts live entries removed to make room under PolicyEvict, and
+	// Expirations expired entries removed by cleanup, by a read or by PurgeExpired.
+	Evictions   uint64
+	Expirations uint64
+
+	// RefreshAheadServed counts reads that returned a live value expiring within the
+	// refresh-ahead window set with WithRefreshAhead, each of which made sure the key was
+	// being refreshed. RefreshAheadTriggered counts the background refreshes they started;
+	// it is lower when several reads arrive while a refresh is running. A high share of
+	// RefreshAheadServed among hits means the window keeps hot keys from expiring.
+	RefreshAheadServed    uint64
+	RefreshAheadTriggered uint64
+}
+
+// Stats returns the current counters of storage.
+func (s *Storage) Stats() Stats {
+	return Stats{
+		Hits:            atomic.LoadUint64(&s.hits),
+		Misses:          atomic.LoadUint64(&s.misses),
+		CleanupOverruns: atomic.LoadUint64(&s.cleanupOverruns),
+		SkippedCleanups: atomic.LoadUint64(&s.skippedCleanups),
+		Evictions:       atomic.LoadUint64(&s.evictions),
+		Expirations:     atomic.LoadUint64(&s.expirations),
+
+		RefreshAheadServed:    atomic.LoadUint64(&s.refreshAheadServed),
+		RefreshAheadTriggered: atomic.LoadUint64(&s.refreshAheadTriggered),
+	}
+}
+
+// Len returns the number of entries in storage, including expired entries not removed yet.
+func (s *Storage) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// ApproxLen returns the number of entries in storage like Len, but from a counter read
+// without taking the lock, so it can be sampled at a high rate without contending with
+// writers. Writes in flight may or may not be counted yet, so under heavy concurrency the
+// result is approximate, but it catches up with Len once writes settle.
+func (s *Storage) ApproxLen() int64 {
+	return atomic.LoadInt64(&s.length)
+}
+
+// resetStats zeros the counters reported by Stats.
+func (s *Storage) resetStats() {
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.misses, 0)
+	atomic.StoreUint64(&s.cleanupOverruns, 0)
+	atomic.StoreUint64(&s.skippedCleanups, 0)
+	atomic.StoreUint64(&s.evictions, 0)
+	atomic.StoreUint64(&s.expirations, 0)
+	atomic.StoreUint64(&s.refreshAheadServed, 0)
+	atomic.StoreUint64(&s.refreshAheadTriggered, 0)
+}
+
+// ShardStat describes the entries and read outcomes of one shard of storage.
+type ShardStat struct {
+	Entries int
+	Hits    uint64
+	Misses  uint64
+}
+
+// ShardStats returns per-shard statistics, which reveal hot-shard skew from a poor key
+// distribution. It is only meaningful for sharded storage; storage backed by a single map
+// reports one element covering every key.
+func (s *Storage) ShardStats() []ShardStat {
+	s.mu.RLock()
+	entries := len(s.data)
+	s.mu.RUnlock()
+
+	return []ShardStat{{
+		Entries: entries,
+		Hits:    atomic.LoadUint64(&s.hits),
+		Misses:  atomic.LoadUint64(&s.misses),
+	}}
+}
+
+// Health summarizes the state of storage for liveness and readiness probes. Deciding what
+// counts as healthy, such as a minimum hit ratio, is left to the caller.
+type Health struct {
+	CleanupRunning bool
+	Closed         bool
+	Entries        int
+	// Capacity is the limit set by WithMaxEntries, or 0 if storage is unbounded.
+	Capacity int
+	Hits     uint64
+	Misses   uint64
+	// HitRatio is Hits / (Hits + Misses), or 0 before the first read.
+	HitRatio float64
+}
+
+// Health returns a summary of the state of storage.
+func (s *Storage) Health() Health {
+	health := Health{
+		CleanupRunning: s.isCleanupRunning(),
+		Closed:         s.isClosed(),
+		Capacity:       s.maxEntries,
+	}
+	s.mu.RLock()
+	health.Entries = len(s.data)
+	s.mu.RUnlock()
+
+	health.Hits = atomic.LoadUint64(&s.hits)
+	health.Misses = atomic.LoadUint64(&s.misses)
+	if reads := health.Hits + health.Misses; reads > 0 {
+		health.HitRatio = float64(health.Hits) / float64(reads)
+	}
+	return health
+}
+
+// Metrics bundles the counters of Stats with the state of storage, as read together by
+// MetricsSnapshot.
+type Metrics struct {
+	Stats
+	Entries        int
+	CleanupRunning bool
+	Closed         bool
+}
+
+// MetricsSnapshot returns the counters and state of storage gathered in one pass under the
+// read lock, which it holds only briefly, so a metrics exporter calling it once per scrape
+// gets a mutually consistent bundle: no entry is stored, removed, evicted or expired
+// between the reads of Entries and the counters. Reads counted in Hits and Misses do not
+// take the write lock and may still land in between.
+func (s *Storage) MetricsSnapshot() Metrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Metrics{
+		Stats:          s.Stats(),
+		Entries:        len(s.data),
+		CleanupRunning: s.isCleanupRunning(),
+		Closed:         s.isClosed(),
+	}
+}
+
+// ExpiredCount returns the number of entries that have expired but not been removed yet,
+// a measure of cleanup lag. Storage keeps no expiration index, so this scans every entry
+// under the read lock in O(n).
+func (s *Storage) ExpiredCount() int {
+	now := s.now()
+	count := 0
+	s.mu.RLock()
+	for _, item := range s.data {
+		if item.isExpiredAt(now) {
+			count++
+		}
+	}
+	s.mu.RUnlock()
+	return count
+}
+
+// TTLHistogram counts live entries by remaining TTL. buckets holds ascending upper bounds:
+// element i of the result counts entries whose remaining TTL is at most buckets[i] and
+// above the previous bound. The result has two more elements than buckets, counting the
+// entries beyond the last bound and the entries that never expire. It is an O(n) snapshot
+// taken under the read lock.
+func (s *Storage) TTLHistogram(buckets []time.Duration) []int {
+	counts := make([]int, len(buckets)+2)
+	now := s.now()
+
+	s.mu.RLock()
+	for _, item := range s.data {
+		switch {
+		case item.expiration.IsZero():
+			counts[len(buckets)+1]++
+		case !item.isExpiredAt(now):
+			remaining := item.expiration.Sub(now)
+			counts[sort.Search(len(buckets), func(i int) bool { return remaining <= buckets[i] })]++
+		}
+	}
+	s.mu.RUnlock()
+	return counts
+}
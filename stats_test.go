@@ -0,0 +1,278 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorage_ShardStats(t *testing.T) {
+	store := New()
+
+	// Insert keys sharing a skewed prefix.
+	for i := 0; i < 100; i++ {
+		store.Set(fmt.Sprintf("hot:%d", i), i, 0)
+	}
+	for i := 0; i < 10; i++ {
+		store.Get(fmt.Sprintf("hot:%d", i))
+	}
+	store.Get("missingKey")
+	store.GetSlice([]string{"hot:0", "missingKey"})
+
+	stats := store.ShardStats()
+	if len(stats) != 1 {
+		t.Fatalf("Expected a single shard, but got %d", len(stats))
+	}
+	if stats[0].Entries != 100 {
+		t.Errorf("Expected 100 entries, but got %d", stats[0].Entries)
+	}
+	if stats[0].Hits != 11 || stats[0].Misses != 2 {
+		t.Errorf("Expected 11 hits and 2 misses, but got %d and %d", stats[0].Hits, stats[0].Misses)
+	}
+}
+
+// recordingLogger records the messages it is given.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+	l.mu.Unlock()
+}
+
+func TestStorage_CleanupOverrun(t *testing.T) {
+	logger := &recordingLogger{}
+	store := New(WithLogger(logger))
+
+	// Inject a slow clock so every cleanup pass appears to take a second.
+	var mu sync.Mutex
+	now := time.Now()
+	store.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(500 * time.Millisecond)
+		return now
+	}
+	for i := 0; i < 100; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+
+	skip := store.runTimedCleanupPass(100 * time.Millisecond)
+	if skip < 1 {
+		t.Errorf("Expected ticks to be skipped after an overrun, but got %d", skip)
+	}
+	if stats := store.Stats(); stats.CleanupOverruns != 1 {
+		t.Errorf("Expected 1 overrun, but got %d", stats.CleanupOverruns)
+	}
+	if len(logger.messages) != 1 {
+		t.Errorf("Expected the overrun to be logged, but got %v", logger.messages)
+	}
+
+	// Test the cleanup goroutine skipping ticks after overruns.
+	store.StartCleanup(10 * time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	store.StopCleanup()
+	if stats := store.Stats(); stats.SkippedCleanups == 0 {
+		t.Errorf("Expected skipped cleanups, but got %+v", stats)
+	}
+
+	// Test a pass within the interval not skipping ticks.
+	store = New()
+	if skip := store.runTimedCleanupPass(time.Hour); skip != 0 {
+		t.Errorf("Expected no skipped ticks, but got %d", skip)
+	}
+}
+
+func TestStorage_Health(t *testing.T) {
+	store := New()
+
+	health := store.Health()
+	if health != (Health{}) {
+		t.Errorf("Expected an empty health summary, but got %+v", health)
+	}
+
+	store.Set("key1", "value1", 0)
+	store.Set("key2", "value2", 0)
+	store.Get("key1")
+	store.Get("key1")
+	store.Get("key2")
+	store.Get("missingKey")
+	store.StartCleanup(time.Minute)
+	defer store.StopCleanup()
+
+	health = store.Health()
+	expected := Health{
+		CleanupRunning: true,
+		Entries:        2,
+		Hits:           3,
+		Misses:         1,
+		HitRatio:       0.75,
+	}
+	if health != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, health)
+	}
+
+	// Test the capacity set by WithMaxEntries being reported.
+	bounded := New(WithMaxEntries(100))
+	if capacity := bounded.Health().Capacity; capacity != 100 {
+		t.Errorf("Expected a capacity of 100, but got %d", capacity)
+	}
+
+	// Test a closed storage being reported as closed, with its cleanup stopped.
+	store.Close()
+	health = store.Health()
+	if !health.Closed || health.CleanupRunning {
+		t.Errorf("Expected closed storage with no cleanup, but got %+v", health)
+	}
+}
+
+func TestStorage_ExpiredCount(t *testing.T) {
+	store := New()
+
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("liveKey%d", i), i, 0)
+	}
+	for i := 0; i < 7; i++ {
+		store.Set(fmt.Sprintf("expiringKey%d", i), i, 50*time.Millisecond)
+	}
+	if count := store.ExpiredCount(); count != 0 {
+		t.Errorf("Expected 0 expired entries, but got %d", count)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if count := store.ExpiredCount(); count != 7 {
+		t.Errorf("Expected 7 expired entries, but got %d", count)
+	}
+
+	store.PurgeExpired()
+	if count := store.ExpiredCount(); count != 0 {
+		t.Errorf("Expected 0 expired entries after purge, but got %d", count)
+	}
+}
+
+func TestStorage_TTLHistogram(t *testing.T) {
+	store := New()
+
+	ttls := []time.Duration{
+		500 * time.Millisecond,
+		10 * time.Second, 30 * time.Second,
+		10 * time.Minute, 20 * time.Minute, 30 * time.Minute,
+		2 * time.Hour,
+		0, 0,
+	}
+	for i, ttl := range ttls {
+		store.Set(fmt.Sprintf("key%d", i), i, ttl)
+	}
+	store.Set("expiredKey", "value", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	counts := store.TTLHistogram([]time.Duration{time.Second, time.Minute, time.Hour})
+	expected := []int{1, 2, 3, 1, 2}
+	if fmt.Sprint(counts) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but got %v", expected, counts)
+	}
+}
+
+func TestStorage_ApproxLen(t *testing.T) {
+	store := New()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	check := func(step string) {
+		t.Helper()
+		if approx, exact := store.ApproxLen(), store.Len(); approx != int64(exact) {
+			t.Errorf("After %s: expected ApproxLen %d, but got %d", step, exact, approx)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	check("sets")
+	store.Set("key0", "overwritten", 0)
+	check("an overwrite")
+	store.Delete("key1")
+	store.Delete("missingKey")
+	check("deletes")
+	store.Set("expiringKey", "value", time.Second)
+	now = now.Add(2 * time.Second)
+	store.PurgeExpired()
+	check("expiry")
+	snap := store.Snapshot()
+	store.Reset()
+	check("Reset")
+	store.Restore(snap)
+	check("Restore")
+	if approx := store.ApproxLen(); approx != 9 {
+		t.Errorf("Expected 9 entries, but got %d", approx)
+	}
+
+	// Test the counter staying correct through concurrent writes.
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("concurrent%d", i%10)
+				if i%3 == 0 {
+					store.Delete(key)
+				} else {
+					store.Set(key, g, 0)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	check("concurrent writes")
+}
+
+// Test that MetricsSnapshot agrees with the values read individually in a quiescent store.
+func TestStorage_MetricsSnapshot(t *testing.T) {
+	store := New()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.StartCleanup(time.Hour)
+	defer store.StopCleanup()
+
+	store.Set("key1", "value", 0)
+	store.Set("key2", "value", 0)
+	store.Set("expiringKey", "value", time.Second)
+	store.Set("key3", "value", 0)
+	store.Get("key1")
+	store.Get("missingKey")
+	now = now.Add(2 * time.Second)
+	store.PurgeExpired()
+
+	metrics := store.MetricsSnapshot()
+	if metrics.Stats != store.Stats() {
+		t.Errorf("Expected %+v, but got %+v", store.Stats(), metrics.Stats)
+	}
+	health := store.Health()
+	if metrics.Entries != store.Len() || metrics.Entries != health.Entries {
+		t.Errorf("Expected %d entries, but got %d", store.Len(), metrics.Entries)
+	}
+	if metrics.CleanupRunning != health.CleanupRunning || !metrics.CleanupRunning {
+		t.Errorf("Expected the cleanup to be running")
+	}
+	if metrics.Hits != 1 || metrics.Misses != 1 || metrics.Expirations != 1 || metrics.Entries != 3 {
+		t.Errorf("Expected 1 hit, 1 miss, 1 expiration and 3 entries, but got %+v", metrics)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "time"
+
+// Store is the core key-value API of Storage, for code that wants to depend on an
+// interface, for example to replace storage with a fake in tests. It covers reading,
+// writing, expiring and deleting single keys and clearing storage. Feature-specific
+// methods of Storage, such as batch operations, counters, loaders and statistics, are
+// left out; code that needs them can declare its own interface with just those methods.
+type Store interface {
+	Get(key string) (interface{}, error)
+	Set(key string, value interface{}, ttl time.Duration) error
+	Has(key string) (bool, error)
+	Touch(key string, ttl time.Duration) error
+	Delete(key string)
+	Reset()
+	Close() error
+}
+
+var _ Store = (*Storage)(nil)
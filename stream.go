@@ -0,0 +1,145 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// KeyInfo describes an entry yielded by Stream or Snapshot.Range.
+type KeyInfo struct {
+	Key        string
+	Value      interface{}
+	Expiration time.Time // zero if the entry does not expire
+	CreatedAt  time.Time // when the value was last written, see CreatedAt
+}
+
+// Stream yields the live entries of storage one at a time, so they can be fed to a slow
+// sink with bounded memory. It snapshots the keys under a brief read lock and then looks
+// each one up as it is sent, so it sees a live view rather than a consistent one: entries
+// deleted or expired since the snapshot are skipped, entries set since are not yielded,
+// and an overwritten entry yields its latest value. Entries whose value cannot be decoded
+// are skipped and logged. The channel is closed when every key has been visited or ctx is
+// done.
+func (s *Storage) Stream(ctx context.Context) <-chan KeyInfo {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+
+	entries := make(chan KeyInfo)
+	go func() {
+		defer close(entries)
+		for _, key := range keys {
+			info, ok := s.keyInfo(key)
+			if !ok {
+				continue
+			}
+			select {
+			case entries <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return entries
+}
+
+// keyInfo returns the live entry stored under key, if any.
+func (s *Storage) keyInfo(key string) (KeyInfo, bool) {
+	s.mu.RLock()
+	item, exists := s.data[key]
+	s.mu.RUnlock()
+	if !exists || item.isExpiredAt(s.now()) {
+		return KeyInfo{}, false
+	}
+
+	value, err := s.readValue(item)
+	if err != nil {
+		s.logger.Printf("Remo: [Stream] skipping key %q: %v", key, err)
+		return KeyInfo{}, false
+	}
+	return KeyInfo{Key: key, Value: value, Expiration: item.expiration, CreatedAt: item.createdAt}, true
+}
+
+// scanEntry is a key visited by Scan and the hash ordering it.
+type scanEntry struct {
+	hash uint64
+	key  string
+}
+
+// Scan pages through the live keys of storage like Redis SCAN. Start with a cursor of 0 and
+// pass each returned nextCursor to the next call; a nextCursor of 0 means the scan is done.
+// Each call returns about count keys, more when several keys share a hash.
+//
+// Keys are visited in the order of a hash of the key rather than by position, so the scan
+// tolerates writes between calls: every key present for the whole scan is returned at
+// least once, while keys set or deleted during the scan may or may not be. Each call
+// hashes every key under the read lock, so a full scan of n keys costs time proportional
+// to n*n/count; it suits admin listings rather than hot paths.
+func (s *Storage) Scan(cursor uint64, count int) (keys []string, nextCursor uint64) {
+	if count <= 0 {
+		count = 1
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	entries := make([]scanEntry, 0, len(s.data))
+	for key, item := range s.data {
+		if item.isExpiredAt(now) {
+			continue
+		}
+		if hash := scanHash(key); hash >= cursor {
+			entries = append(entries, scanEntry{hash: hash, key: key})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].hash != entries[j].hash {
+			return entries[i].hash < entries[j].hash
+		}
+		return entries[i].key < entries[j].key
+	})
+	if len(entries) <= count {
+		for _, entry := range entries {
+			keys = append(keys, entry.key)
+		}
+		return keys, 0
+	}
+	// Keys sharing the hash of the last one are returned together, as the next cursor
+	// cannot point between them.
+	last := entries[count-1].hash
+	for _, entry := range entries {
+		if entry.hash > last {
+			break
+		}
+		keys = append(keys, entry.key)
+	}
+	// A last hash of the maximum value wraps to 0, which correctly ends the scan.
+	return keys, last + 1
+}
+
+// scanHash returns the hash of key ordering the keys visited by Scan.
+func scanHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStorage_Stream(t *testing.T) {
+	store := New()
+	const numEntries = 100
+	for i := 0; i < numEntries; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	store.Set("expiredKey", "value", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	seen := make(map[string]interface{})
+	for info := range store.Stream(context.Background()) {
+		seen[info.Key] = info.Value
+	}
+	if len(seen) != numEntries {
+		t.Errorf("Expected %d entries, but got %d", numEntries, len(seen))
+	}
+	for i := 0; i < numEntries; i++ {
+		if value := seen[fmt.Sprintf("key%d", i)]; value != i {
+			t.Errorf("Expected %d for key%d, but got %v", i, i, value)
+		}
+	}
+}
+
+func TestStorage_StreamCancel(t *testing.T) {
+	store := New()
+	for i := 0; i < 100; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries := store.Stream(ctx)
+	for i := 0; i < 10; i++ {
+		<-entries
+	}
+	cancel()
+
+	// Test the channel being closed without further entries once the stream notices.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case info, ok := <-entries:
+		if ok {
+			t.Errorf("Expected the channel to be closed, but got %v", info)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the channel to be closed after cancellation")
+	}
+}
+
+// Test that paging with Scan covers every key present throughout, despite interleaved writes.
+func TestStorage_Scan(t *testing.T) {
+	store := New()
+	const numEntries = 100
+	for i := 0; i < numEntries; i++ {
+		store.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+	store.Set("expiredKey", "value", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	seen := make(map[string]int)
+	cursor, pages := uint64(0), 0
+	for {
+		keys, next := store.Scan(cursor, 10)
+		for _, key := range keys {
+			seen[key]++
+		}
+		// Interleave writes: add new keys and delete keys not part of the stable set.
+		store.Set(fmt.Sprintf("new%d", pages), pages, 0)
+		store.Delete(fmt.Sprintf("new%d", pages-1))
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < numEntries; i++ {
+		if key := fmt.Sprintf("key%d", i); seen[key] == 0 {
+			t.Errorf("Expected %q to be scanned", key)
+		}
+	}
+	if seen["expiredKey"] != 0 {
+		t.Errorf("Expected the expired key to be skipped")
+	}
+	if pages < numEntries/10 {
+		t.Errorf("Expected at least %d pages, but got %d", numEntries/10, pages)
+	}
+	if keys, next := New().Scan(0, 10); len(keys) != 0 || next != 0 {
+		t.Errorf("Expected an empty scan of empty storage, but got %v, %d", keys, next)
+	}
+}
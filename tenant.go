@@ -0,0 +1,55 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// GetContext retrieves a value like Get, with key scoped to the tenant of ctx. On a miss,
+// ctx is passed to the loader set with WithLoaderContext.
+func (s *Storage) GetContext(ctx context.Context, key string) (value interface{}, err error) {
+	key = s.tenantKey(ctx, key)
+	defer wrapOpError("GetContext", key, &err)
+	value, _, err = s.getDetailed(ctx, key)
+	return value, err
+}
+
+// SetContext sets a key-value pair like Set, with key scoped to the tenant of ctx.
+func (s *Storage) SetContext(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	key = s.tenantKey(ctx, key)
+	defer wrapOpError("SetContext", key, &err)
+	_, err = s.set(ctx, key, value, ttl, false)
+	return err
+}
+
+// DeleteContext removes an item like Delete, with key scoped to the tenant of ctx.
+func (s *Storage) DeleteContext(ctx context.Context, key string) {
+	s.Delete(s.tenantKey(ctx, key))
+}
+
+// tenantKey returns key scoped to the tenant of ctx, as "<tenant length>:<tenant>:<key>".
+func (s *Storage) tenantKey(ctx context.Context, key string) string {
+	if s.tenantFunc == nil || key == "" {
+		return key
+	}
+	tenant := s.tenantFunc(ctx)
+	if tenant == "" {
+		return key
+	}
+	return strconv.Itoa(len(tenant)) + ":" + tenant + ":" + key
+}
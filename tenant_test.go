@@ -0,0 +1,117 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// tenantContextKey is the context key holding the tenant in tests.
+type tenantContextKey struct{}
+
+func withTenant(tenant string) context.Context {
+	return context.WithValue(context.Background(), tenantContextKey{}, tenant)
+}
+
+func TestStorage_Tenants(t *testing.T) {
+	store := New(WithTenantFunc(func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantContextKey{}).(string)
+		return tenant
+	}))
+	acme := withTenant("acme")
+	globex := withTenant("globex")
+
+	// Test two tenants setting the same logical key without collision.
+	store.SetContext(acme, "config", "acmeConfig", 0)
+	store.SetContext(globex, "config", "globexConfig", 0)
+
+	value, err := store.GetContext(acme, "config")
+	if err != nil || value != "acmeConfig" {
+		t.Errorf("Expected acmeConfig, got %v, %v", value, err)
+	}
+	value, err = store.GetContext(globex, "config")
+	if err != nil || value != "globexConfig" {
+		t.Errorf("Expected globexConfig, got %v, %v", value, err)
+	}
+
+	// Test the raw key being distinct from tenant keys.
+	if _, err := store.Get("config"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for the unscoped key, but got %v", err)
+	}
+
+	// Test a tenant not being able to forge another's keys.
+	store.SetContext(withTenant("acme:config"), "", "forged", 0)
+	store.SetContext(withTenant("ac"), "me:config", "forged", 0)
+	value, _ = store.GetContext(acme, "config")
+	if value != "acmeConfig" {
+		t.Errorf("Expected acmeConfig, but got %v", value)
+	}
+
+	// Test GetOrCompute being scoped to the tenant.
+	value, err = store.GetOrCompute(globex, "config", 0, func(ctx context.Context) (interface{}, error) {
+		t.Errorf("Expected the tenant's value to be found")
+		return nil, nil
+	})
+	if err != nil || value != "globexConfig" {
+		t.Errorf("Expected globexConfig, got %v, %v", value, err)
+	}
+
+	// Test deletes only affecting their tenant.
+	store.DeleteContext(acme, "config")
+	if _, err := store.GetContext(acme, "config"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	if _, err := store.GetContext(globex, "config"); err != nil {
+		t.Errorf("Expected globex's key to remain, but got %v", err)
+	}
+
+	// Test a context without a tenant using unscoped keys.
+	store.SetContext(context.Background(), "shared", "value", 0)
+	if _, err := store.Get("shared"); err != nil {
+		t.Errorf("Expected the unscoped key to be set, but got %v", err)
+	}
+
+	// Test GetContext passing ctx to the loader, so each tenant loads its own value.
+	loading := New(
+		WithTenantFunc(func(ctx context.Context) string {
+			tenant, _ := ctx.Value(tenantContextKey{}).(string)
+			return tenant
+		}),
+		WithLoaderContext(func(ctx context.Context, key string) (interface{}, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			tenant, _ := ctx.Value(tenantContextKey{}).(string)
+			return tenant + "Config", nil
+		}),
+	)
+	value, err = loading.GetContext(acme, "config")
+	if err != nil || value != "acmeConfig" {
+		t.Errorf("Expected acmeConfig, got %v, %v", value, err)
+	}
+	value, err = loading.GetContext(globex, "config")
+	if err != nil || value != "globexConfig" {
+		t.Errorf("Expected globexConfig, got %v, %v", value, err)
+	}
+
+	// Test the loader seeing the cancellation of ctx.
+	canceled, cancel := context.WithCancel(acme)
+	cancel()
+	if _, err := loading.GetContext(canceled, "other"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, but got %v", err)
+	}
+}
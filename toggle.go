@@ -0,0 +1,84 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"time"
+)
+
+// Toggle atomically flips the bool stored under key and returns the new value, for
+// feature flags and latches that must not be flipped twice by racing Get and Set calls. A
+// missing or expired key is created as true with the given TTL; an existing key keeps its
+// expiration. It returns ErrWrongType if key holds a value that is not a bool. Toggle is
+// limited by WithPerKeyWriteRate like Set; a dropped flip returns the unchanged value.
+func (s *Storage) Toggle(key string, ttl time.Duration) (value bool, err error) {
+	defer wrapOpError("Toggle", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return false, err
+	}
+
+	s.markActive()
+	if err := s.lock(); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	if err := s.makeRoom(context.Background(), key); err != nil {
+		return false, err
+	}
+	if err := s.checkWritable(key); err != nil {
+		return false, err
+	}
+	allowed := s.allowWrite(key)
+	if !allowed && !s.dropRateLimited {
+		return false, ErrWriteRateExceeded
+	}
+
+	value = true
+	var expiration time.Time
+	if item, exists := s.data[key]; exists && !item.isExpiredAt(s.now()) {
+		current, err := s.decode(item.value)
+		if err != nil {
+			return false, err
+		}
+		flag, ok := current.(bool)
+		if !ok {
+			return false, ErrWrongType
+		}
+		value = !flag
+		expiration = item.expiration
+	} else {
+		ttl, err := s.resolveTTL(key, value, ttl)
+		if err != nil {
+			return false, err
+		}
+		expiration = s.calculateExpiration(ttl)
+	}
+
+	if !allowed {
+		// The dropped flip leaves the flag as it was.
+		return !value, nil
+	}
+
+	stored, err := s.encode(value)
+	if err != nil {
+		return false, err
+	}
+	s.storeItem(key, newItem(stored, expiration))
+	return value, nil
+}
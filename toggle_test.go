@@ -0,0 +1,106 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test that concurrent toggles leave the flag with the parity of their number.
+func TestStorage_Toggle(t *testing.T) {
+	store := New()
+
+	const goroutines, toggles = 10, 101
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < toggles; j++ {
+				if _, err := store.Toggle("flag", time.Minute); err != nil {
+					t.Errorf("Toggle() failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first toggle creates the flag as true, so an odd number of toggles leaves it true.
+	expected := goroutines*toggles%2 == 1
+	if value, err := store.Get("flag"); err != nil || value != expected {
+		t.Errorf("Expected %v, but got %v, %v", expected, value, err)
+	}
+	if value, err := store.Toggle("flag", 0); err != nil || value == expected {
+		t.Errorf("Expected %v, but got %v, %v", !expected, value, err)
+	}
+
+	// Test values that are not bools being rejected.
+	store.Set("notFlag", "value", 0)
+	if _, err := store.Toggle("notFlag", 0); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected %v, but got %v", ErrWrongType, err)
+	}
+}
+
+// Test that Toggle is limited by the per-key write rate like Set.
+func TestStorage_ToggleWriteRate(t *testing.T) {
+	store := New(WithPerKeyWriteRate(1, time.Hour))
+	if value, err := store.Toggle("flag", 0); err != nil || value != true {
+		t.Errorf("Expected true, but got %v, %v", value, err)
+	}
+	if _, err := store.Toggle("flag", 0); !errors.Is(err, ErrWriteRateExceeded) {
+		t.Errorf("Expected %v, but got %v", ErrWriteRateExceeded, err)
+	}
+
+	// Test a dropped flip reporting the unchanged value.
+	store = New(WithPerKeyWriteRate(1, time.Hour), WithDropRateLimitedWrites())
+	store.Toggle("flag", 0)
+	if value, err := store.Toggle("flag", 0); err != nil || value != true {
+		t.Errorf("Expected true, but got %v, %v", value, err)
+	}
+	if value, err := store.Get("flag"); err != nil || value != true {
+		t.Errorf("Expected true, but got %v, %v", value, err)
+	}
+}
+
+// Test that Toggle gives up on a held lock with WithLockTimeout.
+func TestStorage_ToggleLockTimeout(t *testing.T) {
+	store := New(WithLockTimeout(10*time.Millisecond), WithLogger(&recordingLogger{}))
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, err := store.Toggle("flag", 0); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected %v, but got %v", ErrLockTimeout, err)
+	}
+}
+
+// Test that Toggle alone keeps storage alive with WithIdleStoreTimeout.
+func TestStorage_ToggleIdleStoreTimeout(t *testing.T) {
+	store := New(WithIdleStoreTimeout(time.Hour, false), WithLogger(&recordingLogger{}))
+	defer store.Close()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if _, err := store.Toggle("flag", 0); err != nil {
+			t.Fatalf("Toggle() failed: %v", err)
+		}
+		now = now.Add(40 * time.Minute)
+		if store.checkIdle() {
+			t.Errorf("Expected no reset within the idle window of the last toggle")
+		}
+	}
+}
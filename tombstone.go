@@ -0,0 +1,86 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"sort"
+	"time"
+)
+
+// Tombstone records that a key was removed from storage.
+type Tombstone struct {
+	Key       string
+	DeletedAt time.Time
+}
+
+// recordTombstone remembers that key was removed, and forgets tombstones older than the
+// retention set by WithTombstones. The caller must hold the write lock.
+func (s *Storage) recordTombstone(key string) {
+	if s.tombstoneTTL <= 0 {
+		return
+	}
+	now := s.now()
+	s.pruneTombstones(now)
+	s.tombstones = append(s.tombstones, Tombstone{Key: key, DeletedAt: now})
+}
+
+// pruneTombstones forgets tombstones older than the retention set by WithTombstones at now,
+// releasing the list once it is empty. The caller must hold the write lock.
+func (s *Storage) pruneTombstones(now time.Time) {
+	cutoff := now.Add(-s.tombstoneTTL)
+	expired := 0
+	for expired < len(s.tombstones) && s.tombstones[expired].DeletedAt.Before(cutoff) {
+		expired++
+	}
+	switch {
+	case expired == len(s.tombstones):
+		s.tombstones = nil
+	case expired > 0:
+		s.tombstones = append(s.tombstones[:0], s.tombstones[expired:]...)
+	}
+}
+
+// removeExpiredTombstones forgets expired tombstones, for the cleanup passes.
+func (s *Storage) removeExpiredTombstones() {
+	if s.tombstoneTTL <= 0 {
+		return
+	}
+	now := s.now()
+	s.mu.Lock()
+	s.pruneTombstones(now)
+	s.mu.Unlock()
+}
+
+// Tombstones returns the tombstones recorded with WithTombstones for keys removed at or
+// after since, oldest first. A key removed several times has a tombstone for each removal.
+// It returns nil if tombstones are not recorded. Expired tombstones are forgotten on the
+// way, as they are by cleanup passes and new removals.
+func (s *Storage) Tombstones(since time.Time) []Tombstone {
+	if s.tombstoneTTL <= 0 {
+		return nil
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneTombstones(now)
+	if cutoff := now.Add(-s.tombstoneTTL); since.Before(cutoff) {
+		since = cutoff
+	}
+	first := sort.Search(len(s.tombstones), func(i int) bool {
+		return !s.tombstones[i].DeletedAt.Before(since)
+	})
+	return append([]Tombstone(nil), s.tombstones[first:]...)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that deleted and expired keys are returned by Tombstones until the retention passes.
+func TestStorage_Tombstones(t *testing.T) {
+	store := New(WithTombstones(time.Hour))
+	start := time.Now()
+	now := start
+	store.now = func() time.Time { return now }
+
+	store.Set("deleted", "value", 0)
+	store.Set("expiring", "value", time.Minute)
+	store.Set("kept", "value", 0)
+	store.Delete("deleted")
+	store.Delete("missing")
+
+	now = start.Add(2 * time.Minute)
+	store.PurgeExpired()
+
+	tombstones := store.Tombstones(time.Time{})
+	expected := []Tombstone{
+		{Key: "deleted", DeletedAt: start},
+		{Key: "expiring", DeletedAt: start.Add(2 * time.Minute)},
+	}
+	if len(tombstones) != len(expected) {
+		t.Fatalf("Expected %v, but got %v", expected, tombstones)
+	}
+	for i := range expected {
+		if tombstones[i].Key != expected[i].Key || !tombstones[i].DeletedAt.Equal(expected[i].DeletedAt) {
+			t.Errorf("Expected %v, but got %v", expected[i], tombstones[i])
+		}
+	}
+
+	if since := store.Tombstones(start.Add(time.Minute)); len(since) != 1 || since[0].Key != "expiring" {
+		t.Errorf("Expected only the expiring tombstone, but got %v", since)
+	}
+
+	now = start.Add(time.Hour + time.Minute)
+	if since := store.Tombstones(time.Time{}); len(since) != 1 || since[0].Key != "expiring" {
+		t.Errorf("Expected the deleted tombstone to have expired, but got %v", since)
+	}
+
+	if tombstones := New().Tombstones(time.Time{}); tombstones != nil {
+		t.Errorf("Expected no tombstones without WithTombstones, but got %v", tombstones)
+	}
+}
+
+// Test that expired tombstones are released by reads and cleanup passes, not only by removals.
+func TestStorage_TombstonesPruned(t *testing.T) {
+	store := New(WithTombstones(time.Hour))
+	start := time.Now()
+	now := start
+	store.now = func() time.Time { return now }
+
+	store.Set("key", "value", 0)
+	store.Delete("key")
+	now = start.Add(2 * time.Hour)
+	store.runCleanupPass()
+	if store.tombstones != nil {
+		t.Errorf("Expected the cleanup pass to release the tombstones, but got %v", store.tombstones)
+	}
+
+	store.Set("key", "value", 0)
+	store.Delete("key")
+	now = now.Add(2 * time.Hour)
+	if tombstones := store.Tombstones(time.Time{}); len(tombstones) != 0 || store.tombstones != nil {
+		t.Errorf("Expected Tombstones to release the tombstones, but got %v", store.tombstones)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTTL can be passed as a TTL to let the storage choose it with the function set by
+// WithTTLFunc. Without such a function the entry does not expire.
+const DefaultTTL = time.Duration(math.MinInt64)
+
+// NoExpiration is the remaining TTL reported for entries that never expire, by every method
+// that reports one, such as TTL and GetWithTTL. It is only reported: to set an entry that
+// never expires, pass a TTL of 0.
+const NoExpiration = time.Duration(-1)
+
+// extensionResolution is the number of steps probabilistic extension draws its chance from.
+const extensionResolution = 1 << 30
+
+// TTL returns the remaining TTL of the live value stored under key, or NoExpiration if it
+// never expires.
+func (s *Storage) TTL(key string) (ttl time.Duration, err error) {
+	defer wrapOpError("TTL", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return 0, err
+	}
+	if err := s.rlock(); err != nil {
+		return 0, err
+	}
+	item, exists := s.data[key]
+	s.mu.RUnlock()
+
+	now := s.now()
+	if !exists {
+		return 0, keyNotFound(key)
+	}
+	if item.isExpiredAt(now) {
+		return 0, keyExpired(key)
+	}
+	return remainingTTL(item, now), nil
+}
+
+// GetWithTTL is like Get, but also returns the remaining TTL of the value, or NoExpiration
+// if it never expires. The value and its TTL are read from the same entry, so a concurrent
+// Set cannot pair one with the other. Values served from the stale window report a TTL of 0.
+func (s *Storage) GetWithTTL(key string) (value interface{}, ttl time.Duration, err error) {
+	defer wrapOpError("GetWithTTL", key, &err)
+	value, item, _, err := s.getEntry(context.Background(), key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if item != nil {
+		return value, remainingTTL(item, s.now()), nil
+	}
+
+	// The value was just loaded and stored; report the TTL it was stored with.
+	if err := s.rlock(); err != nil {
+		return nil, 0, err
+	}
+	item, exists := s.data[key]
+	s.mu.RUnlock()
+	if !exists {
+		// Deleted since it was loaded.
+		return value, 0, nil
+	}
+	return value, remainingTTL(item, s.now()), nil
+}
+
+// remainingTTL returns the time left before item expires at now, NoExpiration if it never
+// expires, or 0 if it has expired. Every method reporting a remaining TTL uses it, so they
+// agree on permanent entries.
+func remainingTTL(item *item, now time.Time) time.Duration {
+	if item.expiration.IsZero() {
+		return NoExpiration
+	}
+	if remaining := item.expiration.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Touch sets a new TTL on the live value stored under key, keeping the value, its creation
+// time and its access statistics. A TTL of 0 makes it never expire, and DefaultTTL applies
+// the function set by WithTTLFunc.
+func (s *Storage) Touch(key string, ttl time.Duration) (err error) {
+	defer wrapOpError("Touch", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	_, err = s.touch(key, ttl)
+	return err
+}
+
+// GetAndTouch returns the live value stored under key and sets a new TTL on it like Touch,
+// as one operation under the write lock, so the entry cannot expire between the read and
+// the extension. It is meant for sessions, which are read and extended on every request.
+// A TTL of 0 makes the entry never expire. It counts as a read in Stats.
+func (s *Storage) GetAndTouch(key string, ttl time.Duration) (value interface{}, err error) {
+	defer wrapOpError("GetAndTouch", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := s.validateKeyAndTTL(key, ttl); err != nil {
+		return nil, err
+	}
+
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	touched, err := s.touch(key, ttl)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+			atomic.AddUint64(&s.misses, 1)
+		}
+		return nil, err
+	}
+	return s.hit(touched, s.now())
+}
+
+// touch replaces the live item stored under key with one expiring after ttl and returns it.
+// The caller must hold the write lock.
+func (s *Storage) touch(key string, ttl time.Duration) (*item, error) {
+	current, exists := s.data[key]
+	if !exists {
+		return nil, keyNotFound(key)
+	}
+	if current.isExpiredAt(s.now()) {
+		return nil, keyExpired(key)
+	}
+	if current.immutable {
+		return nil, ErrImmutable
+	}
+	value, err := s.decode(current.value)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err = s.resolveTTL(key, value, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.replaceExpiration(key, current, s.calculateExpiration(ttl)), nil
+}
+
+// replaceExpiration replaces current, the item stored under key, with a copy expiring at
+// expiration and returns the copy. The caller must hold the write lock.
+func (s *Storage) replaceExpiration(key string, current *item, expiration time.Time) *item {
+	// Replace the item rather than update it, as readers access items outside the lock.
+	// The value is unchanged, so unlike storeItem this keeps dependencies and dependents.
+	replaced := current.clone()
+	replaced.expiration = s.capLifetime(current.createdAt, expiration)
+	atomic.StoreUint64(&replaced.hits, atomic.LoadUint64(&current.hits))
+	atomic.StoreInt64(&replaced.lastAccess, atomic.LoadInt64(&current.lastAccess))
+	atomic.StoreInt64(&replaced.lastUsed, atomic.LoadInt64(&current.lastUsed))
+	s.data[key] = replaced
+	s.scheduleExpiration(key, replaced.expiration)
+	return replaced
+}
+
+// capLifetime returns expiration, moved earlier if needed so an entry written at createdAt
+// does not outlive the maximum lifetime. A zero expiration stands for never.
+func (s *Storage) capLifetime(createdAt, expiration time.Time) time.Time {
+	if s.maxLifetime <= 0 {
+		return expiration
+	}
+	if limit := createdAt.Add(s.maxLifetime); expiration.IsZero() || expiration.After(limit) {
+		return limit
+	}
+	return expiration
+}
+
+// maybeExtend extends the expiration of item, stored under key, by the extension set with
+// WithProbabilisticExtension if it expires within the threshold, with a probability that
+// grows with its hit rate.
+func (s *Storage) maybeExtend(key string, item *item, now time.Time) {
+	if s.extendThreshold <= 0 || item.expiration.IsZero() || item.expiration.Sub(now) >= s.extendThreshold {
+		return
+	}
+	// The probability is the number of hits expected within the threshold at the item's
+	// average hit rate, with its age counted as at least the threshold.
+	age := now.Sub(item.createdAt)
+	if age < s.extendThreshold {
+		age = s.extendThreshold
+	}
+	p := float64(atomic.LoadUint64(&item.hits)) * float64(s.extendThreshold) / float64(age)
+	if p < 1 && float64(s.randInt63n(extensionResolution)) >= p*extensionResolution {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[key] == item {
+		s.replaceExpiration(key, item, item.expiration.Add(s.extendExtension))
+	}
+}
+
+// ExpiringSoon returns up to n live entries ordered by ascending expiration, soonest first,
+// for watching what is about to vanish or refreshing it ahead of time. Permanent entries,
+// set with a TTL of 0, never expire and are left out. Entries expiring at the same time are
+// ordered by key, and entries whose value cannot be decoded are skipped and logged.
+func (s *Storage) ExpiringSoon(n int) []KeyInfo {
+	if n <= 0 {
+		return nil
+	}
+
+	type entry struct {
+		key  string
+		item *item
+	}
+	now := s.now()
+	s.mu.RLock()
+	entries := make([]entry, 0, len(s.data))
+	for key, item := range s.data {
+		if !item.expiration.IsZero() && !item.isExpiredAt(now) {
+			entries = append(entries, entry{key: key, item: item})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].item.expiration.Equal(entries[j].item.expiration) {
+			return entries[i].item.expiration.Before(entries[j].item.expiration)
+		}
+		return entries[i].key < entries[j].key
+	})
+	infos := make([]KeyInfo, 0, n)
+	for _, entry := range entries {
+		if len(infos) == n {
+			break
+		}
+		value, err := s.readValue(entry.item)
+		if err != nil {
+			s.logger.Printf("Remo: [ExpiringSoon] skipping key %q: %v", entry.key, err)
+			continue
+		}
+		infos = append(infos, KeyInfo{Key: entry.key, Value: value, Expiration: entry.item.expiration, CreatedAt: entry.item.createdAt})
+	}
+	return infos
+}
+
+// TTLRule sets the TTL of entries set with DefaultTTL whose key matches Pattern, a glob
+// in which '*' matches any run of bytes and '?' any single byte. See WithTTLRules.
+type TTLRule struct {
+	Pattern string
+	TTL     time.Duration
+}
+
+// resolveTTL returns the TTL to apply to an entry, computing it when DefaultTTL is given.
+func (s *Storage) resolveTTL(key string, value interface{}, ttl time.Duration) (time.Duration, error) {
+	if ttl != DefaultTTL {
+		return ttl, nil
+	}
+	for _, rule := range s.ttlRules {
+		if matchGlob(rule.Pattern, key) {
+			if rule.TTL < 0 {
+				return 0, ErrNegativeTTL
+			}
+			return rule.TTL, nil
+		}
+	}
+	if s.ttlFunc == nil {
+		return 0, nil
+	}
+	if ttl = s.ttlFunc(key, value); ttl < 0 {
+		return 0, ErrNegativeTTL
+	}
+	return ttl, nil
+}
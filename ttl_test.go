@@ -0,0 +1,405 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorage_TTLFunc(t *testing.T) {
+	store := New(WithTTLFunc(func(key string, value interface{}) time.Duration {
+		switch value.(type) {
+		case []byte:
+			return time.Minute
+		case string:
+			return time.Hour
+		default:
+			return 0
+		}
+	}))
+
+	store.Set("blobKey", []byte("blob"), DefaultTTL)
+	store.Set("configKey", "config", DefaultTTL)
+	store.Set("otherKey", 42, DefaultTTL)
+	store.Set("explicitKey", "config", time.Second)
+
+	assertTTL := func(key string, expected time.Duration) {
+		t.Helper()
+		expiration := store.data[key].expiration
+		if expected == 0 {
+			if !expiration.IsZero() {
+				t.Errorf("Expected %s not to expire, but it expires at %v", key, expiration)
+			}
+			return
+		}
+		if ttl := time.Until(expiration); ttl > expected || ttl < expected-time.Second {
+			t.Errorf("Expected %s to have a TTL of %v, but got %v", key, expected, ttl)
+		}
+	}
+	assertTTL("blobKey", time.Minute)
+	assertTTL("configKey", time.Hour)
+	assertTTL("otherKey", 0)
+
+	// Test an explicit TTL taking precedence over the function.
+	assertTTL("explicitKey", time.Second)
+}
+
+func TestStorage_TTLRules(t *testing.T) {
+	store := New(
+		WithTTLRules([]TTLRule{
+			{Pattern: "session:admin:*", TTL: time.Hour},
+			{Pattern: "session:*", TTL: 30 * time.Minute},
+			{Pattern: "cache:*", TTL: 5 * time.Minute},
+		}),
+		WithTTLFunc(func(key string, value interface{}) time.Duration { return time.Minute }),
+	)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	store.Set("session:admin:1", "value", DefaultTTL)
+	store.Set("session:1", "value", DefaultTTL)
+	store.Set("cache:1", "value", DefaultTTL)
+	store.Set("other", "value", DefaultTTL)
+	store.Set("explicit:session", "value", DefaultTTL)
+	store.Set("session:2", "value", time.Second)
+	store.Set("cache:2", "value", 0)
+
+	expected := map[string]time.Duration{
+		// Test the first matching rule winning over broader later ones.
+		"session:admin:1": time.Hour,
+		"session:1":       30 * time.Minute,
+		"cache:1":         5 * time.Minute,
+		// Test keys matching no rule falling through to the TTL function.
+		"other":            time.Minute,
+		"explicit:session": time.Minute,
+		// Test explicit TTLs taking precedence over the rules.
+		"session:2": time.Second,
+		"cache:2":   NoExpiration,
+	}
+	for key, ttl := range expected {
+		if got, err := store.TTL(key); err != nil || got != ttl {
+			t.Errorf("Expected %s to have a TTL of %v, but got %v, %v", key, ttl, got, err)
+		}
+	}
+}
+
+func TestStorage_DefaultTTLWithoutFunc(t *testing.T) {
+	store := New()
+
+	err := store.Set("key", "value", DefaultTTL)
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if !store.data["key"].expiration.IsZero() {
+		t.Errorf("Expected key not to expire")
+	}
+}
+
+func TestStorage_TTLFuncNegative(t *testing.T) {
+	store := New(WithTTLFunc(func(key string, value interface{}) time.Duration {
+		return -time.Second
+	}))
+
+	err := store.Set("key", "value", DefaultTTL)
+	if !errors.Is(err, ErrNegativeTTL) {
+		t.Errorf("Expected ErrNegativeTTL, but got %v", err)
+	}
+}
+
+func TestStorage_ExpirationGranularity(t *testing.T) {
+	store := New(WithExpirationGranularity(100 * time.Millisecond))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 30*int(time.Millisecond), time.UTC)
+	store.now = func() time.Time { return now }
+
+	// Test expirations snapping up to the next boundary.
+	store.Set("key", "value", 250*time.Millisecond)
+	expected := time.Date(2024, 1, 1, 0, 0, 0, 300*int(time.Millisecond), time.UTC)
+	if expiration := store.data["key"].expiration; !expiration.Equal(expected) {
+		t.Errorf("Expected expiration %v, but got %v", expected, expiration)
+	}
+
+	// Test an expiration already on a boundary being kept.
+	store.Set("boundaryKey", "value", 70*time.Millisecond)
+	expected = time.Date(2024, 1, 1, 0, 0, 0, 100*int(time.Millisecond), time.UTC)
+	if expiration := store.data["boundaryKey"].expiration; !expiration.Equal(expected) {
+		t.Errorf("Expected expiration %v, but got %v", expected, expiration)
+	}
+
+	// Test the key not expiring before its TTL.
+	now = now.Add(250 * time.Millisecond)
+	if _, err := store.Get("key"); err != nil {
+		t.Errorf("Expected key to be live at its exact TTL, but got %v", err)
+	}
+	now = now.Add(60 * time.Millisecond)
+	if _, err := store.Get("key"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired past the boundary, but got %v", err)
+	}
+}
+
+func TestStorage_MaxTTL(t *testing.T) {
+	store := New(WithMaxTTL(time.Hour))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	// Test a TTL over the limit being clamped.
+	store.Set("longKey", "value", 48*time.Hour)
+	if expiration := store.data["longKey"].expiration; !expiration.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected expiration %v, but got %v", now.Add(time.Hour), expiration)
+	}
+
+	// Test a TTL within the limit being kept.
+	store.Set("shortKey", "value", time.Minute)
+	if expiration := store.data["shortKey"].expiration; !expiration.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected expiration %v, but got %v", now.Add(time.Minute), expiration)
+	}
+
+	// Test a TTL of 0 expiring after the limit instead of never.
+	store.Set("permanentKey", "value", 0)
+	if expiration := store.data["permanentKey"].expiration; !expiration.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected expiration %v, but got %v", now.Add(time.Hour), expiration)
+	}
+	now = now.Add(time.Hour + time.Second)
+	if _, err := store.Get("permanentKey"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired after the maximum TTL, but got %v", err)
+	}
+}
+
+func TestStorage_CreatedAt(t *testing.T) {
+	store := New()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	// Test Set stamping the creation time.
+	store.Set("key", "value", time.Minute)
+	created := now
+	if createdAt, err := store.CreatedAt("key"); err != nil || !createdAt.Equal(created) {
+		t.Errorf("Expected %v, got %v, %v", created, createdAt, err)
+	}
+
+	// Test Touch extending the TTL while keeping the creation time.
+	now = now.Add(30 * time.Second)
+	if err := store.Touch("key", time.Minute); err != nil {
+		t.Fatalf("Touch() failed: %v", err)
+	}
+	if createdAt, _ := store.CreatedAt("key"); !createdAt.Equal(created) {
+		t.Errorf("Expected Touch to keep %v, but got %v", created, createdAt)
+	}
+	now = now.Add(45 * time.Second)
+	if _, err := store.Get("key"); err != nil {
+		t.Errorf("Expected Touch to extend the TTL, but got %v", err)
+	}
+
+	// Test overwriting resetting the creation time.
+	store.Set("key", "newValue", time.Minute)
+	if createdAt, _ := store.CreatedAt("key"); !createdAt.Equal(now) {
+		t.Errorf("Expected Set to reset it to %v, but got %v", now, createdAt)
+	}
+
+	// Test missing and expired keys.
+	if _, err := store.CreatedAt("missingKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	if err := store.Touch("missingKey", time.Minute); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound from Touch, but got %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := store.CreatedAt("key"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
+
+func TestStorage_GetAndTouch(t *testing.T) {
+	store := New()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	store.Set("session", "data", time.Minute)
+
+	// Test the value being returned and the expiration pushed out in one call.
+	now = now.Add(50 * time.Second)
+	value, err := store.GetAndTouch("session", time.Minute)
+	if err != nil || value != "data" {
+		t.Errorf("Expected data, got %v, %v", value, err)
+	}
+	if expiration := store.data["session"].expiration; !expiration.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected expiration %v, but got %v", now.Add(time.Minute), expiration)
+	}
+	now = now.Add(30 * time.Second)
+	if _, err := store.Get("session"); err != nil {
+		t.Errorf("Expected the session to outlive its original TTL, but got %v", err)
+	}
+
+	// Test a TTL of 0 making the entry permanent.
+	store.GetAndTouch("session", 0)
+	if expiration := store.data["session"].expiration; !expiration.IsZero() {
+		t.Errorf("Expected no expiration, but got %v", expiration)
+	}
+
+	// Test missing and expired keys.
+	if _, err := store.GetAndTouch("missingKey", time.Minute); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	store.Set("expiredKey", "value", time.Second)
+	now = now.Add(2 * time.Second)
+	if _, err := store.GetAndTouch("expiredKey", time.Minute); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
+
+func TestStorage_ProbabilisticExtension(t *testing.T) {
+	store := New(WithProbabilisticExtension(5*time.Second, time.Minute))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	// Extend whenever the chance is at least one half.
+	store.randInt63n = func(n int64) int64 { return n / 2 }
+	store.Set("hotKey", "value", time.Minute)
+	store.Set("coldKey", "value", time.Minute)
+	store.Set("permanentKey", "value", 0)
+
+	// Read the hot key every second, and the cold key once shortly before it expires.
+	for i := 0; i < 58; i++ {
+		now = now.Add(time.Second)
+		store.Get("hotKey")
+	}
+	store.Get("coldKey")
+	store.Get("permanentKey")
+
+	// Test the hot key surviving its TTL and the cold one expiring.
+	now = now.Add(5 * time.Second)
+	if _, err := store.Get("hotKey"); err != nil {
+		t.Errorf("Expected the hot key to be extended, but got %v", err)
+	}
+	if _, err := store.Get("coldKey"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected the cold key to expire, but got %v", err)
+	}
+
+	// Test keys without a TTL being unaffected.
+	if expiration := store.data["permanentKey"].expiration; !expiration.IsZero() {
+		t.Errorf("Expected no expiration, but got %v", expiration)
+	}
+}
+
+func TestStorage_ExpiringSoon(t *testing.T) {
+	store := New()
+	store.Set("minuteKey", "value", time.Minute)
+	store.Set("secondKey", "value", time.Second)
+	store.Set("hourKey", "value", time.Hour)
+	store.Set("permanentKey", "value", 0)
+	store.Set("dayKey", "value", 24*time.Hour)
+
+	// Test entries being ordered by ascending expiration, without permanent ones.
+	expected := []string{"secondKey", "minuteKey", "hourKey", "dayKey"}
+	infos := store.ExpiringSoon(10)
+	if len(infos) != len(expected) {
+		t.Fatalf("Expected %d entries, but got %d", len(expected), len(infos))
+	}
+	for i, info := range infos {
+		if info.Key != expected[i] {
+			t.Errorf("Expected %s at %d, but got %s", expected[i], i, info.Key)
+		}
+	}
+
+	// Test n limiting the result to the soonest entries.
+	if infos := store.ExpiringSoon(2); len(infos) != 2 || infos[1].Key != "minuteKey" {
+		t.Errorf("Expected secondKey and minuteKey, but got %v", infos)
+	}
+}
+
+func TestStorage_MaxLifetime(t *testing.T) {
+	store := New(WithMaxLifetime(time.Hour))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	store.Set("key", "value", 10*time.Minute)
+	store.Set("permanentKey", "value", 0)
+
+	// Test a constantly touched key still expiring at its maximum lifetime.
+	for i := 0; i < 11; i++ {
+		now = now.Add(5 * time.Minute)
+		if err := store.Touch("key", 10*time.Minute); err != nil {
+			t.Fatalf("Touch() failed: %v", err)
+		}
+	}
+	limit := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	if expiration := store.data["key"].expiration; !expiration.Equal(limit) {
+		t.Errorf("Expected expiration %v, but got %v", limit, expiration)
+	}
+	now = limit.Add(time.Second)
+	if err := store.Touch("key", 10*time.Minute); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired past the maximum lifetime, but got %v", err)
+	}
+
+	// Test permanent keys expiring at their maximum lifetime too.
+	if _, err := store.Get("permanentKey"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
+
+func TestStorage_NoExpiration(t *testing.T) {
+	store := New()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	store.Set("permanentKey", "value", 0)
+	store.Set("ttlKey", "value", time.Minute)
+	now = now.Add(10 * time.Second)
+
+	// Test every TTL-reporting method agreeing on permanent and expiring keys.
+	for key, expected := range map[string]time.Duration{"permanentKey": NoExpiration, "ttlKey": 50 * time.Second} {
+		if ttl, err := store.TTL(key); err != nil || ttl != expected {
+			t.Errorf("Expected TTL %v for %s, got %v, %v", expected, key, ttl, err)
+		}
+		if _, ttl, err := store.GetWithTTL(key); err != nil || ttl != expected {
+			t.Errorf("Expected GetWithTTL %v for %s, got %v, %v", expected, key, ttl, err)
+		}
+	}
+
+	// Test missing and expired keys.
+	if _, err := store.TTL("missingKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, _, err := store.GetWithTTL("ttlKey"); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
+
+// Test that GetWithTTL never pairs a value with the TTL of another write racing with it.
+func TestStorage_GetWithTTLConsistent(t *testing.T) {
+	store := New()
+	store.Set("key", "short", time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			store.Set("key", "long", time.Hour)
+			store.Set("key", "short", time.Minute)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		value, ttl, err := store.GetWithTTL("key")
+		if err != nil {
+			t.Fatalf("GetWithTTL() failed: %v", err)
+		}
+		if (value == "short") != (ttl <= time.Minute) {
+			t.Fatalf("Expected a TTL matching %v, but got %v", value, ttl)
+		}
+	}
+}
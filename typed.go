@@ -0,0 +1,49 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "context"
+
+// GetString is like Get for string values. It returns ErrWrongType, rather than panicking,
+// if the value stored under key is not a string, so a value whose type changed between
+// versions of a deployment is reported as an error.
+func (s *Storage) GetString(key string) (str string, err error) {
+	defer wrapOpError("GetString", key, &err)
+	value, _, err := s.getDetailed(context.Background(), key)
+	if err != nil {
+		return "", err
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", ErrWrongType
+	}
+	return str, nil
+}
+
+// GetInt is like Get for integer values. Signed integers of any size are converted to an
+// int64, as with Increment. It returns ErrWrongType, rather than panicking, if the value
+// stored under key is not a signed integer.
+func (s *Storage) GetInt(key string) (n int64, err error) {
+	defer wrapOpError("GetInt", key, &err)
+	value, _, err := s.getDetailed(context.Background(), key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := toInt64(value)
+	if !ok {
+		return 0, ErrWrongType
+	}
+	return n, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStorage_TypedGetters(t *testing.T) {
+	store := New()
+	store.Set("stringKey", "value", 0)
+	store.Set("intKey", int32(42), 0)
+
+	if str, err := store.GetString("stringKey"); err != nil || str != "value" {
+		t.Errorf("Expected value, got %q, %v", str, err)
+	}
+	if n, err := store.GetInt("intKey"); err != nil || n != 42 {
+		t.Errorf("Expected 42, got %d, %v", n, err)
+	}
+
+	// Test a value of another type being a clean error rather than a panic.
+	if _, err := store.GetString("intKey"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, but got %v", err)
+	}
+	if _, err := store.GetInt("stringKey"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, but got %v", err)
+	}
+
+	// Test lookup errors being passed through.
+	if _, err := store.GetString("missingKey"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+// UpdateInPlace passes the value stored under key to mutate, which modifies it in place,
+// keeping the key's expiration. It is meant for pointer values shared with other holders:
+// the map keeps the same pointer, so every holder sees the change. mutate runs under the
+// write lock, so it must be fast and must not call back into the storage. The lock does
+// not protect holders that read or write the value outside the storage at the same time;
+// they need their own synchronization. If mutate returns an error, it is returned,
+// along with whatever changes mutate made before failing.
+//
+// Values that are not pointers, or that reference no shared data, are passed by copy, so
+// mutating them has no effect. With WithSerializedValues, mutate is given a decoded copy
+// that is encoded and stored back when it succeeds, so identity is not preserved.
+func (s *Storage) UpdateInPlace(key string, mutate func(value interface{}) error) (err error) {
+	defer wrapOpError("UpdateInPlace", key, &err)
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	item, exists := s.data[key]
+	if !exists {
+		return keyNotFound(key)
+	}
+	if item.isExpiredAt(s.now()) {
+		return keyExpired(key)
+	}
+	if item.immutable {
+		return ErrImmutable
+	}
+
+	if s.codec == nil {
+		if err := mutate(item.value); err != nil {
+			return err
+		}
+		s.lastVersion++
+		item.version = s.lastVersion
+		s.invalidateDependents(key)
+		s.publish(EventSet, key)
+		return nil
+	}
+
+	value, err := s.decode(item.value)
+	if err != nil {
+		return err
+	}
+	if err := mutate(value); err != nil {
+		return err
+	}
+	stored, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	if err := s.checkValueSize(stored); err != nil {
+		return err
+	}
+	s.storeItem(key, newItem(stored, item.expiration))
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorage_UpdateInPlace(t *testing.T) {
+	type session struct {
+		User  string
+		Views int
+	}
+	store := New()
+	shared := &session{User: "alice"}
+	store.Set("session", shared, time.Minute)
+	expiration := store.data["session"].expiration
+
+	// Test the mutation being visible through an external reference.
+	err := store.UpdateInPlace("session", func(value interface{}) error {
+		value.(*session).Views++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateInPlace() failed: %v", err)
+	}
+	if shared.Views != 1 {
+		t.Errorf("Expected the external reference to see 1 view, but got %d", shared.Views)
+	}
+	value, _ := store.Get("session")
+	if value != shared {
+		t.Errorf("Expected the stored pointer to be kept, but got %p", value)
+	}
+	if !store.data["session"].expiration.Equal(expiration) {
+		t.Errorf("Expected the expiration to be kept")
+	}
+
+	// Test errors from mutate being returned.
+	errInvalid := errors.New("invalid")
+	err = store.UpdateInPlace("session", func(value interface{}) error {
+		return errInvalid
+	})
+	if !errors.Is(err, errInvalid) {
+		t.Errorf("Expected errInvalid, but got %v", err)
+	}
+
+	// Test missing and expired keys.
+	mutate := func(value interface{}) error {
+		t.Errorf("Expected mutate not to be called")
+		return nil
+	}
+	if err := store.UpdateInPlace("missingKey", mutate); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, but got %v", err)
+	}
+	store.Set("expiredKey", &session{}, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	if err := store.UpdateInPlace("expiredKey", mutate); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, but got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "fmt"
+
+// Validate checks the internal consistency of storage and returns an error describing the
+// first broken invariant, or nil. It is meant for tests and debugging: it holds the read
+// lock while it visits every entry.
+func (s *Storage) Validate() error {
+	if err := s.rlock(); err != nil {
+		return err
+	}
+	defer s.mu.RUnlock()
+
+	for key, item := range s.data {
+		if item == nil {
+			return fmt.Errorf("nil item stored under %q", key)
+		}
+		if _, marked := s.markers[key]; marked {
+			return fmt.Errorf("exists loader answer cached for stored key %q", key)
+		}
+	}
+	if s.peakLen < len(s.data) {
+		return fmt.Errorf("peak length %d below the %d stored entries", s.peakLen, len(s.data))
+	}
+
+	for key, dependencies := range s.dependencies {
+		if _, exists := s.data[key]; !exists {
+			return fmt.Errorf("dependencies recorded for missing key %q", key)
+		}
+		for _, dependency := range dependencies {
+			if _, linked := s.dependents[dependency][key]; !linked {
+				return fmt.Errorf("%q depends on %q, which does not list it as a dependent", key, dependency)
+			}
+		}
+	}
+	for dependency, dependents := range s.dependents {
+		for key := range dependents {
+			if !containsString(s.dependencies[key], dependency) {
+				return fmt.Errorf("%q lists %q as a dependent, which does not depend on it", dependency, key)
+			}
+		}
+	}
+
+	if s.interned != nil {
+		if len(s.interned) != len(s.data) {
+			return fmt.Errorf("%d interned keys for %d stored entries", len(s.interned), len(s.data))
+		}
+		for key := range s.data {
+			if _, interned := s.interned[key]; !interned {
+				return fmt.Errorf("stored key %q is not interned", key)
+			}
+		}
+	}
+	return nil
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
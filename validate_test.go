@@ -0,0 +1,38 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "testing"
+
+func TestStorage_Validate(t *testing.T) {
+	store := New(WithKeyInterning())
+	store.Set("parent", "value", 0)
+	store.SetWithDependency("child", "value", 0, "parent")
+	if err := store.Validate(); err != nil {
+		t.Fatalf("Expected consistent storage, but got %v", err)
+	}
+
+	// Test broken invariants being reported.
+	delete(store.dependents["parent"], "child")
+	if err := store.Validate(); err == nil {
+		t.Errorf("Expected an error for an unlinked dependency")
+	}
+	store.dependents["parent"]["child"] = struct{}{}
+
+	delete(store.interned, "child")
+	if err := store.Validate(); err == nil {
+		t.Errorf("Expected an error for a key that is not interned")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "time"
+
+// ExpirationStrategy selects how the cleanup goroutine finds expired entries.
+type ExpirationStrategy int
+
+const (
+	// StrategyScan checks every entry on each cleanup pass. It needs no extra memory, but
+	// each pass costs time proportional to the number of entries, expired or not.
+	StrategyScan ExpirationStrategy = iota
+
+	// StrategyTimerWheel files entries with a TTL into buckets, one per granularity
+	// interval (see WithExpirationGranularity), and each cleanup pass removes only the
+	// entries of the buckets that have come due. A pass then costs time proportional to the
+	// number of entries expiring, but each write of an entry with a TTL adds a bucket slot
+	// that is only reclaimed once its bucket comes due, even if the entry was overwritten
+	// or deleted meanwhile. Entries outlive their TTL by up to the granularity plus the
+	// cleanup interval.
+	StrategyTimerWheel
+)
+
+// defaultWheelGranularity is the bucket width of the timer wheel when no expiration
+// granularity is set.
+const defaultWheelGranularity = time.Second
+
+// wheelBucket returns the bucket of an expiration: the first one whose boundary is not
+// before it.
+func (s *Storage) wheelBucket(expiration time.Time) int64 {
+	return (expiration.UnixNano()-1)/int64(s.granularity) + 1
+}
+
+// scheduleExpiration files key into the timer wheel bucket of expiration. It does nothing
+// unless the timer wheel strategy is in use. The caller must hold the write lock.
+func (s *Storage) scheduleExpiration(key string, expiration time.Time) {
+	if s.wheel == nil || expiration.IsZero() {
+		return
+	}
+	bucket := s.wheelBucket(expiration.Add(s.staleWindow))
+	s.wheel[bucket] = append(s.wheel[bucket], key)
+}
+
+// resetWheel empties the timer wheel and files the entries of data into it. The caller
+// must hold the write lock.
+func (s *Storage) resetWheel(data map[string]*item) {
+	if s.wheel == nil {
+		return
+	}
+	s.wheel = make(map[int64][]string)
+	s.wheelTick = 0
+	for key, item := range data {
+		s.scheduleExpiration(key, item.expiration)
+	}
+}
+
+// advanceWheel removes the entries of every bucket that has come due, along with expired
+// exists loader answers.
+func (s *Storage) advanceWheel() {
+	now := s.now()
+	// Buckets up to due have their boundary strictly before now, so their entries have expired.
+	due := (now.UnixNano() - 1) / int64(s.granularity)
+
+	var expired []KeyValue
+	s.mu.Lock()
+	if due-s.wheelTick > int64(len(s.wheel)) {
+		// After a long pause it is cheaper to visit the buckets than the elapsed ticks.
+		for bucket, keys := range s.wheel {
+			if bucket <= due {
+				expired = s.expireBucket(bucket, keys, now, expired)
+			}
+		}
+	} else {
+		for bucket := s.wheelTick + 1; bucket <= due; bucket++ {
+			if keys, ok := s.wheel[bucket]; ok {
+				expired = s.expireBucket(bucket, keys, now, expired)
+			}
+		}
+	}
+	if due > s.wheelTick {
+		s.wheelTick = due
+	}
+	s.mu.Unlock()
+	s.notifyExpired(expired)
+
+	s.removeExpiredMarkers()
+}
+
+// expireBucket removes the entries of a due bucket that are still stored and expired,
+// appending them to expired as expireItem does. Keys overwritten with a later expiration
+// are skipped, as they are filed in a later bucket. The caller must hold the write lock.
+func (s *Storage) expireBucket(bucket int64, keys []string, now time.Time, expired []KeyValue) []KeyValue {
+	for _, key := range keys {
+		if item, exists := s.data[key]; exists && s.isReclaimable(item, now) {
+			expired = s.expireItem(key, item, expired)
+		}
+	}
+	delete(s.wheel, bucket)
+	return expired
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStorage_TimerWheel(t *testing.T) {
+	store := New(WithExpirationStrategy(StrategyTimerWheel), WithExpirationGranularity(100*time.Millisecond))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Set("shortKey", "value", 150*time.Millisecond)
+	store.Set("longKey", "value", time.Second)
+	store.Set("persistentKey", "value", 0)
+	store.Set("overwrittenKey", "value", 150*time.Millisecond)
+	store.Set("overwrittenKey", "value", time.Second)
+	store.Set("deletedKey", "value", 150*time.Millisecond)
+	store.Delete("deletedKey")
+
+	// Test a pass before anything is due removing nothing.
+	now = now.Add(100 * time.Millisecond)
+	store.runCleanupPass()
+	if len(store.data) != 4 {
+		t.Errorf("Expected 4 entries, but got %d", len(store.data))
+	}
+
+	// Test a pass removing only the entries that are due.
+	now = now.Add(150 * time.Millisecond)
+	store.runCleanupPass()
+	if _, exists := store.data["shortKey"]; exists {
+		t.Errorf("Expected shortKey to be removed")
+	}
+	for _, key := range []string{"longKey", "persistentKey", "overwrittenKey"} {
+		if _, exists := store.data[key]; !exists {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+
+	// Test a pass after a long pause draining the wheel.
+	now = now.Add(time.Hour)
+	store.runCleanupPass()
+	if len(store.data) != 1 {
+		t.Errorf("Expected only persistentKey to remain, but got %d entries", len(store.data))
+	}
+	if len(store.wheel) != 0 {
+		t.Errorf("Expected an empty wheel, but got %d buckets", len(store.wheel))
+	}
+
+	// Test restored entries being filed into the wheel.
+	snap := New()
+	snap.Set("restoredKey", "value", time.Minute)
+	store.Restore(snap.Snapshot())
+	if len(store.wheel) != 1 {
+		t.Errorf("Expected restored entries in the wheel, but got %d buckets", len(store.wheel))
+	}
+}
+
+// BenchmarkExpirationStrategy measures a cleanup pass over 1M keys with 1s TTLs, both
+// before the keys are due and once they have all expired.
+func BenchmarkExpirationStrategy(b *testing.B) {
+	const numKeys = 1000000
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	for _, bm := range []struct {
+		name     string
+		strategy ExpirationStrategy
+	}{
+		{"Scan", StrategyScan},
+		{"TimerWheel", StrategyTimerWheel},
+	} {
+		var now time.Time
+		populate := func() *Storage {
+			store := New(WithExpirationStrategy(bm.strategy))
+			now = time.Now()
+			store.now = func() time.Time { return now }
+			for _, key := range keys {
+				store.Set(key, 0, time.Second)
+			}
+			return store
+		}
+
+		b.Run(bm.name+"/Idle", func(b *testing.B) {
+			store := populate()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.runCleanupPass()
+			}
+		})
+
+		b.Run(bm.name+"/Expire", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				store := populate()
+				now = now.Add(2 * time.Second)
+				b.StartTimer()
+				store.runCleanupPass()
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import "time"
+
+// writeBucket is the token bucket limiting the write rate of one key.
+type writeBucket struct {
+	tokens float64
+	last   time.Time // when tokens was last refilled
+}
+
+// allowWrite takes a token from the write bucket of key, creating a full one for a key
+// without one, and reports whether there was one to take. It always allows writes without
+// WithPerKeyWriteRate. The caller must hold the write lock.
+func (s *Storage) allowWrite(key string) bool {
+	if s.writeRateLimit <= 0 {
+		return true
+	}
+
+	now := s.now()
+	limit := float64(s.writeRateLimit)
+	bucket, exists := s.writeBuckets[key]
+	if !exists {
+		if s.writeBuckets == nil {
+			s.writeBuckets = make(map[string]*writeBucket)
+		}
+		bucket = &writeBucket{tokens: limit, last: now}
+		s.writeBuckets[key] = bucket
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens += limit * float64(elapsed) / float64(s.writeRateWindow)
+		if bucket.tokens > limit {
+			bucket.tokens = limit
+		}
+		bucket.last = now
+	}
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
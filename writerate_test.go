@@ -0,0 +1,80 @@
+// Copyright 2023 itpey
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test that writes beyond the per-key rate are rejected until the bucket refills.
+func TestStorage_PerKeyWriteRate(t *testing.T) {
+	store := New(WithPerKeyWriteRate(3, 3*time.Second))
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := store.Set("hotKey", i, 0); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	if err := store.Set("hotKey", 3, 0); !errors.Is(err, ErrWriteRateExceeded) {
+		t.Errorf("Expected %v, but got %v", ErrWriteRateExceeded, err)
+	}
+	if value, _ := store.Get("hotKey"); value != 2 {
+		t.Errorf("Expected 2, but got %v", value)
+	}
+
+	// Test other keys having their own bucket.
+	if err := store.Set("otherKey", "value", 0); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	// Test a third of the window refilling one token.
+	now = now.Add(time.Second)
+	if err := store.Set("hotKey", 4, 0); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if err := store.Set("hotKey", 5, 0); !errors.Is(err, ErrWriteRateExceeded) {
+		t.Errorf("Expected %v, but got %v", ErrWriteRateExceeded, err)
+	}
+
+	// Test the bucket filling up again after the window passes.
+	now = now.Add(3 * time.Second)
+	for i := 0; i < 3; i++ {
+		if err := store.Set("hotKey", i, 0); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	}
+
+	// Test the bucket being removed with the key.
+	store.Delete("hotKey")
+	if _, exists := store.writeBuckets["hotKey"]; exists {
+		t.Errorf("Expected the bucket to be removed with the key")
+	}
+}
+
+// Test that writes beyond the per-key rate are dropped silently with WithDropRateLimitedWrites.
+func TestStorage_DropRateLimitedWrites(t *testing.T) {
+	store := New(WithPerKeyWriteRate(1, time.Hour), WithDropRateLimitedWrites())
+	store.Set("key", "first", 0)
+	if err := store.Set("key", "second", 0); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if value, _ := store.Get("key"); value != "first" {
+		t.Errorf("Expected first, but got %v", value)
+	}
+}